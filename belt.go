@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// eccentricities are clipped below this, so no belt member falls into its parent
+const maxBeltEccentricity = 0.9
+
+// Belt describes a population of small bodies around a parent.
+// A ring is a belt with a narrow radial range and no inclination spread.
+type Belt struct {
+	Name              string
+	Parent            string
+	Texture           string
+	Count             int
+	Inner             float64 // smallest semi-major axis
+	Outer             float64 // largest semi-major axis
+	Inclination       float64 // scale of the rayleigh distributed inclinations
+	Eccentricity      float64 // scale of the rayleigh distributed eccentricities
+	MassMin           float64 `toml:"mass_min"`
+	MassMax           float64 `toml:"mass_max"`
+	Diameter          float64
+	Seed              int64
+	RadiationPressure bool `toml:"radiation_pressure"` // members are pushed by the light of luminous bodies like dust
+	Reflectivity      float64
+}
+
+func rayleigh(rng *rand.Rand, scale float64) float64 {
+	return scale * math.Sqrt(-2*math.Log(1-rng.Float64()))
+}
+
+// Expands the belt into tracer particles on keplerian orbits around the parent.
+// The same seed always yields the same belt.
+func expandBelt(b Belt, parent *Particle, k *Constants) ParticleSystem {
+	rng := rand.New(rand.NewSource(b.Seed))
+	mu := k.G * parent.Mass
+
+	r := make(ParticleSystem, b.Count)
+	for i := range r {
+		var o OrbitalElements
+		o.SemiMajorAxis = b.Inner + rng.Float64()*(b.Outer-b.Inner)
+		o.Eccentricity = math.Min(rayleigh(rng, b.Eccentricity), maxBeltEccentricity)
+		o.Inclination = rayleigh(rng, b.Inclination)
+		o.Node = rng.Float64() * 2 * math.Pi
+		o.Periapsis = rng.Float64() * 2 * math.Pi
+		o.TrueAnomaly = trueAnomalyFromMean(rng.Float64()*2*math.Pi, o.Eccentricity)
+		mass := b.MassMin + rng.Float64()*(b.MassMax-b.MassMin)
+
+		pos, vel := StateFromElements(o, mu)
+		r[i] = Particle{parent.Position.Add(pos), parent.Velocity.Add(vel), mass, 0, true, mgl64.QuatIdent(), mgl64.Vec3{}}
+	}
+	return r
+}
@@ -1,6 +1,16 @@
 package main
 
-import "github.com/go-gl/mathgl/mgl64"
+import (
+	"math"
+
+	"github.com/go-gl/gl/v2.1/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+type CameraConfig struct {
+	LogarithmicDepth bool `toml:"logarithmic_depth"`
+}
 
 type Camera struct {
 	position    *mgl64.Vec3
@@ -10,8 +20,75 @@ type Camera struct {
 	aspect      float64
 	near        float64
 	far         float64
+	logDepth    bool // replaces the hyperbolic depth of the projection by log2(d/near + 1) / log2(far/near + 1)
 }
 
+// The view is built with the camera at the origin, so everything drawn with it has to be
+// relative to the camera (see Model and Relative). Subtracting the camera position in double
+// precision before the conversion to float32 keeps vertices far from the origin from jittering.
 func (c *Camera) Perspective() mgl64.Mat4 {
-	return mgl64.Perspective(c.fovY, c.aspect, c.near*glCorrectionScale, c.far*glCorrectionScale).Mul4(mgl64.LookAtV(c.position.Mul(glCorrectionScale), c.position.Mul(glCorrectionScale).Add(*c.orientation), *c.up))
+	return mgl64.Perspective(c.fovY, c.aspect, c.near*glCorrectionScale, c.far*glCorrectionScale).Mul4(mgl64.LookAtV(mgl64.Vec3{0, 0, 0}, *c.orientation, *c.up))
+}
+
+// Window depth in [0, 1] of a point at distance d in front of the camera.
+// Mirrors what the shaders compute, see Uniforms.Set.
+func (c *Camera) Depth(d float64) float64 {
+	if c.logDepth {
+		return math.Log2(d/c.near+1) / math.Log2(c.far/c.near+1)
+	}
+	ndc := (c.far+c.near)/(c.far-c.near) - 2*c.far*c.near/((c.far-c.near)*d)
+	return (ndc + 1) / 2
+}
+
+// World size of one pixel at distance d in front of the camera.
+func (c *Camera) PixelSize(d float64) float64 {
+	return 2 * d * math.Tan(c.fovY/2) / height
+}
+
+// Uniforms shared by every program that draws with the camera.
+type Uniforms struct {
+	view      int32
+	depthC    int32
+	depthCoef int32
+}
+
+func getUniforms(program uint32) Uniforms {
+	return Uniforms{
+		gl.GetUniformLocation(program, gl.Str("view\x00")),
+		gl.GetUniformLocation(program, gl.Str("depthC\x00")),
+		gl.GetUniformLocation(program, gl.Str("depthCoef\x00")),
+	}
+}
+
+// Sets the view and the depth mode of the camera. A depth coefficient of zero keeps the default depth.
+func (u *Uniforms) Set(c *Camera) {
+	d := Mat4f64Tof32(c.Perspective())
+	gl.UniformMatrix4fv(u.view, 1, false, &d[0])
+	depthC, depthCoef := c.depthUniforms()
+	gl.Uniform1f(u.depthC, depthC)
+	gl.Uniform1f(u.depthCoef, depthCoef)
+}
+
+// Values of the depthC and depthCoef uniforms. The shaders write log2(depthC * w + 1) * depthCoef
+// as depth, where w is the distance in gl units.
+func (c *Camera) depthUniforms() (float32, float32) {
+	if !c.logDepth {
+		return 0, 0
+	}
+	return float32(1 / (c.near * glCorrectionScale)), float32(1 / math.Log2(c.far/c.near+1))
+}
+
+// Moves the model matrix t, which is given in world space scaled by glCorrectionScale, relative to the camera.
+func (c *Camera) Model(t mgl64.Mat4) mgl32.Mat4 {
+	p := c.position.Mul(glCorrectionScale)
+	t[12] -= p[0]
+	t[13] -= p[1]
+	t[14] -= p[2]
+	return Mat4f64Tof32(t)
+}
+
+// Position p in world space relative to the camera and scaled by glCorrectionScale.
+func (c *Camera) Relative(p mgl64.Vec3) mgl32.Vec3 {
+	r := p.Sub(*c.position).Mul(glCorrectionScale)
+	return mgl32.Vec3{float32(r[0]), float32(r[1]), float32(r[2])}
 }
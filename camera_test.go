@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+func testCamera(position mgl64.Vec3, logDepth bool) *Camera {
+	orientation, up := mgl64.Vec3{0, 0, 1}, mgl64.Vec3{0, 1, 0}
+	return &Camera{&position, &orientation, &up, math.Pi / 4, 4.0 / 3.0, 1e7, 1e12, logDepth}
+}
+
+// Far from the origin, positions relative to the camera keep sub-metre precision in float32,
+// which the absolute positions lose.
+func TestRelativePrecision(t *testing.T) {
+	camera := mgl64.Vec3{1e12, -3e11, 2e11}
+	c := testCamera(camera, false)
+	for _, offset := range []mgl64.Vec3{{0.3, 0, 0}, {-0.7, 0.25, 0.1}, {12.5, -3.75, 0.01}} {
+		p := camera.Add(offset)
+		r := c.Relative(p)
+		for k := 0; k < 3; k++ {
+			got := float64(r[k]) / glCorrectionScale
+			if d := math.Abs(got - offset[k]); d > 1e-3 {
+				t.Errorf("relative %v: component %d off by %v m", offset, k, d)
+			}
+		}
+
+		m := c.Model(mgl64.Translate3D(p[0]*glCorrectionScale, p[1]*glCorrectionScale, p[2]*glCorrectionScale))
+		for k := 0; k < 3; k++ {
+			got := float64(m[12+k]) / glCorrectionScale
+			if d := math.Abs(got - offset[k]); d > 1e-3 {
+				t.Errorf("model %v: component %d off by %v m", offset, k, d)
+			}
+		}
+	}
+
+	// for comparison, the difference of the absolute positions in float32 loses the offset
+	absolute := float64(float32((camera[0]+0.3)*glCorrectionScale)-float32(camera[0]*glCorrectionScale)) / glCorrectionScale
+	if math.Abs(absolute-0.3) < 0.1 {
+		t.Errorf("absolute float32 positions resolve the offset as %v m, expected them to be coarser", absolute)
+	}
+}
+
+// The shaders compute the depth from the float32 uniforms, in float32 (see Uniforms.Set).
+func shaderDepth(c *Camera, d float64) float64 {
+	depthC, depthCoef := c.depthUniforms()
+	w := float32(d * glCorrectionScale)
+	return float64(float32(math.Log2(float64(depthC*w+1))) * depthCoef)
+}
+
+// Value of a depth in [0, 1] in a 24 bit depth buffer.
+func depthBits(z float64) uint32 {
+	return uint32(math.Round(z * (1<<24 - 1)))
+}
+
+func TestDepth(t *testing.T) {
+	c := testCamera(mgl64.Vec3{}, true)
+	c.near, c.far = 1, 1e13
+	last := -1.0
+	for d := 1.0; d <= 1e13; d *= 1.5 {
+		z := c.Depth(d)
+		if z < 0 || z > 1 {
+			t.Fatalf("depth %v at %v m outside [0, 1]", z, d)
+		}
+		if z <= last {
+			t.Fatalf("depth %v at %v m not above %v", z, d, last)
+		}
+		last = z
+
+		if s := shaderDepth(c, d); math.Abs(s-z) > 1e-6 {
+			t.Fatalf("depth %v at %v m, the shader computes %v", z, d, s)
+		}
+	}
+	if z := c.Depth(1e13); math.Abs(z-1) > 1e-12 {
+		t.Errorf("depth %v at the far plane", z)
+	}
+
+	// neighbours stay apart in the depth buffer, from landing on a moon to across the solar system;
+	// the logarithmic depth resolves about 2e-6 of the distance, so 1e6 m at 1e12 m is near its limit
+	for _, n := range []struct{ d, step float64 }{{1e3, 1}, {1e12, 1e6}} {
+		if a, b := depthBits(shaderDepth(c, n.d)), depthBits(shaderDepth(c, n.d+n.step)); a == b {
+			t.Errorf("%v m and %v m share the depth %d", n.d, n.d+n.step, a)
+		}
+	}
+
+	// the hyperbolic depth spends its precision close to the near plane
+	c.logDepth = false
+	c.near, c.far = 1e7, 1e12
+	if _, depthCoef := c.depthUniforms(); depthCoef != 0 {
+		t.Errorf("depth coefficient %v replaces the hyperbolic depth", depthCoef)
+	}
+	if z := c.Depth(c.near); math.Abs(z) > 1e-12 {
+		t.Errorf("hyperbolic depth %v at the near plane", z)
+	}
+	if z := c.Depth(1e10); z < 0.999 {
+		t.Errorf("hyperbolic depth %v at 1e10 m", z)
+	}
+}
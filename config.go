@@ -1,46 +1,231 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"log"
+	"math"
 
 	"github.com/BurntSushi/toml"
 	"github.com/go-gl/mathgl/mgl64"
 )
 
 type Celestialbody struct {
-	Name     string
-	Texture  string
-	Distance float64
-	Speed    float64
-	Mass     float64
-	Diameter float64
+	Name            string
+	Texture         string
+	Parent          string
+	Distance        float64
+	Speed           float64
+	Mass            float64
+	Diameter        float64
+	RotationPeriod  float64   `toml:"rotation_period"` // sidereal, negative for retrograde rotation
+	Tilt            float64   // of the rotation axis against the pole of the orbit
+	Rotation        float64   // initial rotation angle
+	Inertia         []float64 // principal moments of inertia along the body axes, enables attitude dynamics
+	Torque          []float64 // constant, in the body frame, turns bodies with an inertia tensor
+	AngularVelocity []float64 `toml:"angular_velocity"` // in the body frame, overrides the rotation period
+	J2              float64   // zonal harmonic coefficients of the oblateness
+	J3              float64
+	J4              float64
+	ReferenceRadius float64 `toml:"reference_radius"` // of the zonal harmonics, half the diameter if not set
+	Atmosphere      *Atmosphere
+	DragCoefficient float64 `toml:"drag_coefficient"`
+	Area            float64 // cross section facing the flow or the light, for drag and radiation pressure
+	Reflectivity    float64 // share of the light reflected by the area
+	Luminosity      float64 // radiated power, pushing bodies with an area
+	Tides           *Tides
+	Lagrange        string // L1 to L5 of the parent and its primary, replaces distance and speed and makes the body a tracer
+	Tracer          bool
+	Emissive        bool
+	DryMass         float64      `toml:"dry_mass"` // below which maneuvers with a specific impulse do not burn
+	Trail           *bool        // shown unless set to false
+	Scale           *VisualScale // overrides the scale of the scene
 }
 
 type Config struct {
-	Bodies []Celestialbody
+	Bodies     []Celestialbody
+	Belts      []Belt
+	Trails     TrailConfig
+	Prediction PredictionConfig
+	Camera     CameraConfig
+	Scale      VisualScale
+	Maneuvers  []Maneuver
+	Transfer   TransferConfig
+	Events     []EventConfig
+	Lagrange   []LagrangeConfig // pairs whose lagrange points are marked
+	Frame      FrameConfig
+	Physics    PhysicsConfig
+	Units      UnitsConfig
 }
 
-func constructSystem(filepath string) (ParticleSystem, []float64, []uint32, []string) {
-	var c Config
-	if _, err := toml.DecodeFile(filepath, &c); err != nil {
+// Body holds everything about a body that is not part of the integrated state.
+type Body struct {
+	Name     string
+	Texture  uint32
+	Radius   float64
+	Parent   int  // index of the configured parent, -1 if none
+	Small    bool // member of a belt, rendered with the shared low-poly mesh
+	Trail    bool
+	Scale    *VisualScale // nil if the scale of the scene applies
+	Emissive bool
+	DryMass  float64    // left once the propellant of the maneuvers is spent
+	Inertia  mgl64.Mat3 // in the body frame, zero for bodies spinning at a prescribed rate
+}
+
+func bodyIndex(bodies []Celestialbody, name string) int {
+	for i, b := range bodies {
+		if b.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Reads the config, whose values may carry units, and converts it into the configured units,
+// which are returned alongside.
+func loadConfig(filepath string) (Config, Units) {
+	var raw map[string]interface{}
+	if _, err := toml.DecodeFile(filepath, &raw); err != nil {
+		log.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(resolveQuantities(raw)); err != nil {
 		log.Fatal(err)
 	}
+	var c Config
+	if _, err := toml.Decode(buf.String(), &c); err != nil {
+		log.Fatalf("%s: %v", filepath, err)
+	}
+	if c.Trails.Length == 0 {
+		c.Trails.Length = defaultTrailLength
+	}
+	if c.Trails.Interval == 0 {
+		c.Trails.Interval = defaultTrailInterval
+	}
+	if c.Scale.Factor == 0 {
+		c.Scale.Factor = defaultScaleFactor
+	}
+	if c.Scale.MinPixels == 0 {
+		c.Scale.MinPixels = defaultMinPixels
+	}
+	for _, b := range c.Bodies {
+		if b.Scale != nil && b.Scale.Factor == 0 {
+			b.Scale.Factor = c.Scale.Factor
+		}
+		if b.Scale != nil && b.Scale.MinPixels == 0 {
+			b.Scale.MinPixels = c.Scale.MinPixels
+		}
+	}
+	if c.Prediction.Steps == 0 {
+		c.Prediction.Steps = defaultPredictionSteps
+	}
+	if c.Prediction.Step == 0 {
+		c.Prediction.Step = defaultPredictionStep
+	}
+	if c.Transfer.Samples == 0 {
+		c.Transfer.Samples = defaultTransferSamples
+	}
+	u := newUnits(c)
+	c.convert(u)
+	return c, u
+}
+
+// Range is the half-open range [First, End) of particle indices.
+type Range struct {
+	First int
+	End   int
+}
+
+// Layout tells where the configured bodies and belts are in the particle system.
+type Layout struct {
+	Bodies Range   // in the order of the config
+	Belts  []Range // the members of each belt, in the order of the config
+}
+
+// An untilted body stands upright on the ecliptic: the north pole of the body frame points along
+// eclipticZ, so bodies with a positive rotation period spin in the sense of the configured orbits.
+var upright = mgl64.QuatRotate(math.Pi, mgl64.Vec3{1, 0, 0})
+
+// Particle of a configured body, before it is placed at a lagrange point.
+func newParticle(b Celestialbody) Particle {
+	t := Particle{mgl64.Vec3{b.Distance, 0, 0}, mgl64.Vec3{0, 0, b.Speed}, b.Mass, 0, b.Tracer, mgl64.QuatIdent(), mgl64.Vec3{}}
+	t.Orientation = upright.Mul(mgl64.QuatRotate(b.Tilt, mgl64.Vec3{1, 0, 0})).Mul(mgl64.QuatRotate(b.Rotation, mgl64.Vec3{0, 1, 0}))
+	if b.RotationPeriod != 0 {
+		t.AngularVelocity = t.Orientation.Rotate(mgl64.Vec3{0, 1, 0}).Mul(2 * math.Pi / b.RotationPeriod)
+	}
+	if len(b.AngularVelocity) == 3 {
+		t.AngularVelocity = t.Orientation.Rotate(mgl64.Vec3{b.AngularVelocity[0], b.AngularVelocity[1], b.AngularVelocity[2]})
+	}
+	return t
+}
+
+// Inertia tensor of a configured body in the body frame, zero for bodies spinning at a prescribed rate.
+func inertia(b Celestialbody) mgl64.Mat3 {
+	if len(b.Inertia) != 3 {
+		return mgl64.Mat3{}
+	}
+	return mgl64.Diag3(mgl64.Vec3{b.Inertia[0], b.Inertia[1], b.Inertia[2]})
+}
+
+func constructSystem(c Config, k *Constants) (ParticleSystem, []Body, Layout) {
 	var rp ParticleSystem
-	var rr []float64
-	var textures []uint32
-	var names []string
+	var rb []Body
+	var l Layout
 	for i, b := range c.Bodies {
-		t := Particle{mgl64.Vec3{b.Distance, 0, 0}, mgl64.Vec3{0, 0, b.Speed}, b.Mass, 0}
-		rp = append(rp, t)
-		rr = append(rr, b.Diameter/2)
+		rp = append(rp, newParticle(b))
+		parent := -1
+		if b.Parent != "" {
+			if parent = bodyIndex(c.Bodies, b.Parent); parent == -1 {
+				log.Fatalf("parent %q of %s does not exist", b.Parent, b.Name)
+			}
+		}
 		text, err := newTexture("textures/" + b.Texture)
 		fmt.Printf("Loading %s (%d/%d)     \r", b.Name, i, len(c.Bodies))
 		if err != nil {
 			log.Fatal(err)
 		}
-		textures = append(textures, text)
-		names = append(names, b.Name)
+		trail := b.Trail == nil || *b.Trail
+		rb = append(rb, Body{b.Name, text, b.Diameter / 2, parent, false, trail, b.Scale, b.Emissive, b.DryMass, inertia(b)})
+	}
+
+	// placed once all bodies are known, as the pair may be configured later
+	prim := primaries(rp, rb)
+	for i, b := range c.Bodies {
+		if b.Lagrange == "" {
+			continue
+		}
+		k, err := lagrangeIndex(b.Lagrange)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s := rb[i].Parent
+		if s == -1 || prim[s] == -1 {
+			log.Fatalf("%s at %s needs a parent with a primary", b.Name, b.Lagrange)
+		}
+		l := LagrangePoints(&rp[prim[s]], &rp[s])[k]
+		rp[i].Position = l.Position
+		rp[i].Velocity = l.Velocity
+		rp[i].Tracer = true
+	}
+
+	l.Bodies = Range{0, len(rp)}
+
+	for _, b := range c.Belts {
+		parent := bodyIndex(c.Bodies, b.Parent)
+		if parent == -1 {
+			log.Fatalf("parent %q of %s does not exist", b.Parent, b.Name)
+		}
+		text, err := newTexture("textures/" + b.Texture)
+		fmt.Printf("Loading %s     \r", b.Name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		first := len(rp)
+		for i, p := range expandBelt(b, &rp[parent], k) {
+			rp = append(rp, p)
+			rb = append(rb, Body{fmt.Sprintf("%s %d", b.Name, i), text, b.Diameter / 2, parent, true, false, nil, false, 0, mgl64.Mat3{}})
+		}
+		l.Belts = append(l.Belts, Range{first, len(rp)})
 	}
-	return rp, rr, textures, names
+	return rp, rb, l
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// The bodies of the shipped config spin in the sense of their orbits around the sun, tilted by
+// their obliquity, unless their rotation period is negative.
+func TestSpinAlongOrbit(t *testing.T) {
+	c, _ := loadConfig("solar_system.toml")
+	sun := newParticle(c.Bodies[bodyIndex(c.Bodies, "sun")])
+	for _, name := range []string{"earth", "mars", "venus", "uranus"} {
+		b := c.Bodies[bodyIndex(c.Bodies, name)]
+		p := newParticle(b)
+		h := p.Position.Sub(sun.Position).Cross(p.Velocity.Sub(sun.Velocity))
+		want := math.Abs(b.Tilt)
+		if b.RotationPeriod < 0 {
+			want = math.Pi - want
+		}
+		if got := angleBetween(p.AngularVelocity, h); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s spins at %.2f° to its orbital angular momentum, want %.2f°", name, got*180/math.Pi, want*180/math.Pi)
+		}
+	}
+}
@@ -34,6 +34,13 @@ func (p *Pov) FPSMove(delta mgl64.Vec3) {
 	p.Position = p.Position.Add(om.Mul3x1(delta)) // for now
 }
 
+// Moves the point of view along with the frame from before to after, so it keeps its place in the frame.
+// The up vector stays fixed for the fps controls.
+func (p *Pov) Carry(before, after *FrameState) {
+	p.Position = after.World(before.Position(p.Position))
+	p.Orientation = after.axes.Mul3(before.axes.Transpose()).Mul3x1(p.Orientation)
+}
+
 type Controls struct {
 	Window       glfw.Window
 	Mouse        mgl64.Vec2
@@ -43,9 +50,18 @@ type Controls struct {
 	Resistance   float64
 	Locked       bool
 	PlanetIndex  int
+	Export       bool // set for one frame when the export key is pressed
+	ShowTrails   bool
+	ShowPaths    bool
+	CycleScale   bool // set for one frame when the scale key is pressed
+	PlanTransfer bool // set for one frame when the transfer key is pressed
+	CycleFrame   bool // set for one frame when the frame key is pressed
+	Warp         int  // +1 or -1 for one frame when the time warp is raised or lowered
+	held         map[glfw.Key]bool
 }
 
 func (c *Controls) Setup() {
+	c.held = make(map[glfw.Key]bool)
 	c.Window.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
 	if !glfw.RawMouseMotionSupported() {
 		log.Fatalln("raw mouse motion not supported")
@@ -55,6 +71,14 @@ func (c *Controls) Setup() {
 	c.Mouse[0], c.Mouse[1] = c.Window.GetCursorPos()
 }
 
+// reports whether the key went down since the last call
+func (c *Controls) pressed(k glfw.Key) bool {
+	down := c.Window.GetKey(k) == glfw.Press
+	r := down && !c.held[k]
+	c.held[k] = down
+	return r
+}
+
 func (c *Controls) Handle(particles []Particle, dt float64) {
 	var mouse mgl64.Vec2
 	glfw.PollEvents()
@@ -96,13 +120,30 @@ func (c *Controls) Handle(particles []Particle, dt float64) {
 	if stop == glfw.Press {
 		c.Velocity = mgl64.Vec3{0, 0, 0}
 	}
+	c.Export = c.pressed(glfw.KeyE)
+	if c.pressed(glfw.KeyT) {
+		c.ShowTrails = !c.ShowTrails
+	}
+	c.CycleScale = c.pressed(glfw.KeyV)
+	c.PlanTransfer = c.pressed(glfw.KeyM)
+	c.CycleFrame = c.pressed(glfw.KeyF)
+	c.Warp = 0
+	if c.pressed(glfw.KeyPeriod) {
+		c.Warp++
+	}
+	if c.pressed(glfw.KeyComma) {
+		c.Warp--
+	}
+	if c.pressed(glfw.KeyP) {
+		c.ShowPaths = !c.ShowPaths
+	}
 
 	if lock == glfw.Press {
 		c.Locked = true
 		planet := particles[c.PlanetIndex]
 
+		// the camera is carried along by the frame of the planet, see Pov.Carry
 		c.P.FreeMove(c.Velocity.Mul(dt))
-		c.P.Position = c.P.Position.Add(planet.Velocity.Mul(dt))
 
 		c.P.Orientation = planet.Position.Sub(c.P.Position).Normalize()
 
@@ -6,6 +6,8 @@ import (
 	"github.com/go-gl/mathgl/mgl64"
 )
 
+const secondsPerDay = 86400.0
+
 type Info struct {
 	Position    *mgl64.Vec3
 	Inertia     *mgl64.Vec3
@@ -13,24 +15,53 @@ type Info struct {
 	CpuTime     *float64
 	GpuTime     *float64
 	DeltaTime   *float64
-	Planets     *[]string
+	Bodies      *[]Body
+	Particles   *ParticleSystem
+	Constants   *Constants
+	Primaries   *[]int
+	Soi         *SOITracker
+	Events      *Detector
+	Scale       *VisualScale
+	Frame       *Frame
 	Locked      *bool
 	PlanetIndex *int
+	Warp        *float64
 }
 
 func (i *Info) Print() {
+	bodies := *i.Bodies
 	locked := "none"
 	if *i.Locked {
-		locked = (*i.Planets)[*i.PlanetIndex]
+		locked = bodies[*i.PlanetIndex].Name
 	}
 
 	fmt.Print("\033[H\033[2J") //clears the screen
 	fmt.Printf(
-		"Position: (%e, %e, %e), Inertia: (%e, %e, %e),	Orientation: (%f, %f, %f), Locked: %s, CPU: %.2f ms, GPU: %.2f ms, FPS: %.2f ",
+		"Position: (%e, %e, %e), Inertia: (%e, %e, %e),	Orientation: (%f, %f, %f), Locked: %s, Scale: %s, Frame: %s, Warp: %gx, CPU: %.2f ms, GPU: %.2f ms, FPS: %.2f \n",
 		i.Position[0], i.Position[1], i.Position[2],
 		i.Inertia[0], i.Inertia[1], i.Inertia[2],
 		i.Orientation[0], i.Orientation[1], i.Orientation[2],
-		locked,
+		locked, i.Scale.Mode, i.Frame.Kind, *i.Warp,
 		*i.CpuTime*1000, *i.GpuTime*1000, 1.0 / *i.DeltaTime,
 	)
+
+	ps := *i.Particles
+	j := *i.PlanetIndex
+	k := (*i.Primaries)[j]
+	for _, o := range i.Events.Recent {
+		fmt.Println(o.String())
+	}
+	fmt.Printf("%s in the sphere of influence of %s, ", bodies[j].Name, primaryName(bodies, i.Soi.Current[j]))
+	if k < 0 {
+		fmt.Printf("%s: no primary ", bodies[j].Name)
+		return
+	}
+	o := ps[j].Elements(&ps[k], i.Constants)
+	fmt.Printf(
+		"%s around %s: a: %e m, e: %f, i: %.2f°, Ω: %.2f°, ω: %.2f°, ν: %.2f°, T: %.2f d ",
+		bodies[j].Name, bodies[k].Name,
+		units.ToSI(o.SemiMajorAxis, dimLength), o.Eccentricity,
+		mgl64.RadToDeg(o.Inclination), mgl64.RadToDeg(o.Node), mgl64.RadToDeg(o.Periapsis), mgl64.RadToDeg(o.TrueAnomaly),
+		days(o.Period),
+	)
 }
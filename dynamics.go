@@ -0,0 +1,247 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// PhysicsConfig switches the corrections to newtonian gravity for the whole system.
+type PhysicsConfig struct {
+	Relativity bool        // first post-newtonian correction, e.g. for the precession of mercury
+	Shadow     ShadowModel // of the radiation pressure
+}
+
+// Dynamics is the right-hand side of the equations of motion: newtonian gravity and attitude
+// as in dParticleSystem, plus the enabled corrections.
+type Dynamics struct {
+	Constants  Constants // in the units of the system
+	relativity bool
+	oblate     []int // bodies with zonal harmonics
+	zonal      []Zonal
+	atmosphere []int // bodies with an atmosphere
+	air        []Atmosphere
+	radius     []float64 // of the bodies with an atmosphere
+	dragged    []int     // bodies with a drag coefficient and area
+	cda        []float64 // drag coefficient times area
+
+	luminous       []int
+	luminosity     []float64
+	sourceRadius   []float64
+	occulters      []int // massive bodies casting shadows
+	occulterRadius []float64
+	irradiated     []int     // bodies with an area
+	cra            []float64 // pressure coefficient times area
+	shadow         ShadowModel
+
+	tidal []tidalPair
+
+	rigid   []int        // bodies whose attitude follows the torques
+	inertia []mgl64.Mat3 // of the rigid bodies, in the body frame
+	torqued []int        // bodies with an applied torque
+	torque  []mgl64.Vec3 // in the body frame
+}
+
+// Atmosphere is an exponential atmosphere, which rotates with its body.
+type Atmosphere struct {
+	Density     float64 // at the surface
+	ScaleHeight float64 `toml:"scale_height"`
+}
+
+// Density at the altitude h above the surface, the surface density below it.
+func (a *Atmosphere) DensityAt(h float64) float64 {
+	return a.Density * math.Exp(-math.Max(h, 0)/a.ScaleHeight)
+}
+
+// Takes the per-body parameters from the configured bodies and belts, which are placed in ps as given by l,
+// and the inertia tensors from bodies, which match ps. The dynamics only apply to states laid out like ps,
+// see PlanetDynamics for the planets alone. Time lags of tides given by a quality factor are fixed at the state ps.
+func NewDynamics(c Config, ps ParticleSystem, bodies []Body, l Layout, k Constants) *Dynamics {
+	d := &Dynamics{Constants: k, relativity: c.Physics.Relativity, shadow: c.Physics.Shadow}
+	body := func(i int) int { return l.Bodies.First + i }
+	for i, b := range c.Bodies {
+		if b.J2 == 0 && b.J3 == 0 && b.J4 == 0 {
+			continue
+		}
+		z := Zonal{[5]float64{0, 0, b.J2, b.J3, b.J4}, b.ReferenceRadius}
+		if z.Radius == 0 {
+			z.Radius = b.Diameter / 2
+		}
+		d.oblate = append(d.oblate, body(i))
+		d.zonal = append(d.zonal, z)
+	}
+	for i, b := range c.Bodies {
+		if b.Atmosphere != nil {
+			d.atmosphere = append(d.atmosphere, body(i))
+			d.air = append(d.air, *b.Atmosphere)
+			d.radius = append(d.radius, b.Diameter/2)
+		}
+		if b.DragCoefficient*b.Area > 0 {
+			d.dragged = append(d.dragged, body(i))
+			d.cda = append(d.cda, b.DragCoefficient*b.Area)
+		}
+	}
+	for i, b := range c.Bodies {
+		switch {
+		case b.Luminosity > 0:
+			d.luminous = append(d.luminous, body(i))
+			d.luminosity = append(d.luminosity, b.Luminosity)
+			d.sourceRadius = append(d.sourceRadius, b.Diameter/2)
+		case !b.Tracer && b.Diameter > 0:
+			d.occulters = append(d.occulters, body(i))
+			d.occulterRadius = append(d.occulterRadius, b.Diameter/2)
+		}
+		if b.Area > 0 {
+			d.irradiated = append(d.irradiated, body(i))
+			d.cra = append(d.cra, (1+b.Reflectivity)*b.Area)
+		}
+	}
+	for k, b := range c.Belts {
+		if !b.RadiationPressure {
+			continue
+		}
+		for i := l.Belts[k].First; i < l.Belts[k].End; i++ {
+			d.irradiated = append(d.irradiated, i)
+			d.cra = append(d.cra, (1+b.Reflectivity)*math.Pi*b.Diameter*b.Diameter/4)
+		}
+	}
+	for i, b := range bodies {
+		if b.Inertia != (mgl64.Mat3{}) {
+			d.rigid = append(d.rigid, i)
+			d.inertia = append(d.inertia, b.Inertia)
+		}
+	}
+	for j, b := range c.Bodies {
+		if b.Tides == nil {
+			continue
+		}
+		r := b.Diameter / 2
+		tidal := &ps[body(j)]
+		if !d.isRigid(body(j)) {
+			// the spin takes up the tidal torque
+			factor := b.Tides.InertiaFactor
+			if factor == 0 {
+				factor = defaultInertiaFactor
+			}
+			d.rigid = append(d.rigid, body(j))
+			d.inertia = append(d.inertia, mgl64.Ident3().Mul(factor*tidal.Mass*r*r))
+		}
+		for i := range c.Bodies {
+			if i == j || ps[body(i)].Tracer {
+				continue
+			}
+			lag := b.Tides.TimeLag
+			if lag == 0 && b.Tides.Q > 0 {
+				lag = timeLag(tidal, &ps[body(i)], b.Tides.Q, &k)
+			}
+			d.tidal = append(d.tidal, tidalPair{body(j), body(i), b.Tides.Love, lag, r})
+		}
+	}
+	for i, b := range c.Bodies {
+		if len(b.Torque) == 3 && d.isRigid(body(i)) {
+			d.torqued = append(d.torqued, body(i))
+			d.torque = append(d.torque, mgl64.Vec3{b.Torque[0], b.Torque[1], b.Torque[2]})
+		}
+	}
+	return d
+}
+
+// Dynamics of the configured bodies without the belts, for the prefix of the system that the
+// predictor, the transfer planner and the time warp integrate.
+func PlanetDynamics(c Config, ps ParticleSystem, bodies []Body, l Layout, k Constants) *Dynamics {
+	c.Belts = nil
+	return NewDynamics(c, ps[:l.Bodies.End], bodies[:l.Bodies.End], Layout{Bodies: l.Bodies}, k)
+}
+
+func (d *Dynamics) Derivative(y *ParticleSystem, dy *ParticleSystem) {
+	dParticleSystem(&d.Constants, y, dy)
+	if d.relativity {
+		d.relativistic(*y, *dy)
+	}
+	d.oblateness(*y, *dy)
+	d.drag(*y, *dy)
+	d.radiation(*y, *dy)
+	d.tides(*y, *dy)
+	d.attitude(*y, *dy)
+}
+
+func (d *Dynamics) isRigid(i int) bool {
+	for _, j := range d.rigid {
+		if i == j {
+			return true
+		}
+	}
+	return false
+}
+
+// Turns the torques, which the forces collect in the angular velocity of dy, into the angular
+// acceleration of the rigid bodies. The other bodies keep spinning at their rate.
+func (d *Dynamics) attitude(y, dy ParticleSystem) {
+	for k, i := range d.torqued {
+		dy[i].AngularVelocity = dy[i].AngularVelocity.Add(y[i].Orientation.Rotate(d.torque[k]))
+	}
+	for k, i := range d.rigid {
+		dy[i].AngularVelocity = y[i].AngularAcceleration(d.inertia[k], dy[i].AngularVelocity)
+	}
+}
+
+// Adds the post-newtonian correction in the field of every massive body.
+func (d *Dynamics) relativistic(y, dy ParticleSystem) {
+	massive := massiveIndices(y)
+	for i := range y {
+		for _, j := range massive {
+			if i != j {
+				dy[i].Velocity = dy[i].Velocity.Add(y[i].RelativisticAccelerationV(&y[j], &d.Constants))
+			}
+		}
+	}
+}
+
+// Adds the pull of the zonal harmonics of the oblate bodies, massive bodies pull back on them.
+func (d *Dynamics) oblateness(y, dy ParticleSystem) {
+	for k, j := range d.oblate {
+		for i := range y {
+			if i == j {
+				continue
+			}
+			a := y[i].ZonalAccelerationV(&y[j], &d.zonal[k], &d.Constants)
+			dy[i].Velocity = dy[i].Velocity.Add(a)
+			if !y[i].Tracer {
+				dy[j].Velocity = dy[j].Velocity.Sub(a.Mul(y[i].Mass / y[j].Mass))
+			}
+		}
+	}
+}
+
+// Decelerates the dragged bodies against the air of every atmosphere, which moves with the
+// rotation of its body. The bodies with an atmosphere are too heavy to feel the reaction.
+func (d *Dynamics) drag(y, dy ParticleSystem) {
+	for k, i := range d.dragged {
+		if y[i].Mass == 0 {
+			continue
+		}
+		for l, j := range d.atmosphere {
+			if i == j {
+				continue
+			}
+			r := y[i].Position.Sub(y[j].Position)
+			rho := d.air[l].DensityAt(r.Len() - d.radius[l])
+			v := y[i].Velocity.Sub(y[j].Velocity).Sub(y[j].AngularVelocity.Cross(r))
+			a := v.Mul(-0.5 * rho * d.cda[k] * v.Len() / y[i].Mass)
+			dy[i].Velocity = dy[i].Velocity.Add(a)
+		}
+	}
+}
+
+// Events for every dragged body falling below the surface of a body with an atmosphere.
+func (d *Dynamics) ReentryEvents(bodies []Body) []*Event {
+	var r []*Event
+	for _, i := range d.dragged {
+		for l, j := range d.atmosphere {
+			if i != j {
+				r = append(r, ReentryEvent(i, j, d.radius[l], bodies))
+			}
+		}
+	}
+	return r
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// Direction of periapsis of b around a, the eccentricity vector.
+func periapsisDirection(a, b *Particle, k *Constants) mgl64.Vec3 {
+	r := b.Position.Sub(a.Position)
+	v := b.Velocity.Sub(a.Velocity)
+	return v.Cross(r.Cross(v)).Mul(1 / b.Mu(a, k)).Sub(r.Normalize())
+}
+
+// Integrates Sun and Mercury for a julian century and returns the turn of the periapsis in arc seconds.
+func mercuryPrecession(relativity bool) float64 {
+	const (
+		a = 5.7909e10
+		e = 0.2056
+		h = 0.1 * secondsPerDay
+	)
+	sun := Particle{Mass: solarMass, Orientation: mgl64.QuatIdent()}
+	mercury := Particle{Mass: 3.3011e23, Orientation: mgl64.QuatIdent()}
+	mu := G * (sun.Mass + mercury.Mass)
+	mercury.Position = mgl64.Vec3{a * (1 - e), 0, 0}
+	mercury.Velocity = mgl64.Vec3{0, 0, math.Sqrt(mu * (1 + e) / (a * (1 - e)))}
+
+	ps := ParticleSystem{sun, mercury}
+	c := Config{Physics: PhysicsConfig{Relativity: relativity}}
+	d := NewDynamics(c, ps, nil, Layout{Bodies: Range{0, len(ps)}}, siConstants)
+	w := newRK4Workspace(len(ps))
+	p0 := periapsisDirection(&ps[0], &ps[1], &d.Constants)
+	for i := 0; i < int(36525*secondsPerDay/h); i++ {
+		stepParticleSystem(&w, d.Derivative, h, &ps)
+	}
+	p1 := periapsisDirection(&ps[0], &ps[1], &d.Constants)
+
+	// the orbit is prograde around the angular momentum
+	n := ps[1].Position.Sub(ps[0].Position).Cross(ps[1].Velocity.Sub(ps[0].Velocity))
+	turn := math.Atan2(p0.Cross(p1).Dot(n.Normalize()), p0.Dot(p1))
+	return turn * 180 / math.Pi * 3600
+}
+
+// The post-newtonian correction turns the perihelion of mercury by 43″ per century.
+func TestMercuryPerihelion(t *testing.T) {
+	if testing.Short() {
+		t.Skip("integrates a century")
+	}
+	// the newtonian run takes out the drift of the integrator
+	newtonian := mercuryPrecession(false)
+	drift := mercuryPrecession(true) - newtonian
+	if math.Abs(drift-43) > 1 {
+		t.Errorf("perihelion turned by %.2f″ per century, newtonian %.2f″", drift, newtonian)
+	}
+}
+
+// An inclined satellite of an oblate earth regresses its node at the secular rate of J2.
+func TestNodalRegression(t *testing.T) {
+	const (
+		j2     = 1.08263e-3
+		radius = 6.3781e6
+		a      = 7e6
+		e      = 0.01
+		incl   = 51.6 * math.Pi / 180
+		days   = 10
+		h      = 10.0
+	)
+	c := Config{Bodies: []Celestialbody{
+		{Name: "earth", Mass: 5.9722e24, Diameter: 2 * radius, J2: j2},
+		{Name: "satellite", Mass: 1e3, Tracer: true},
+	}}
+	earth := newParticle(c.Bodies[0])
+	mu := G * earth.Mass
+	speed := math.Sqrt(mu * (1 + e) / (a * (1 - e)))
+	// at periapsis, prograde around the pole of the upright earth, which is the pole of the ecliptic
+	satellite := Particle{
+		Position:    mgl64.Vec3{a * (1 - e), 0, 0},
+		Velocity:    mgl64.Vec3{0, math.Sin(incl), math.Cos(incl)}.Mul(speed),
+		Mass:        c.Bodies[1].Mass,
+		Tracer:      true,
+		Orientation: mgl64.QuatIdent(),
+	}
+
+	ps := ParticleSystem{earth, satellite}
+	d := NewDynamics(c, ps, nil, Layout{Bodies: Range{0, len(ps)}}, siConstants)
+	w := newRK4Workspace(len(ps))
+	pole := earth.Orientation.Rotate(mgl64.Vec3{0, 1, 0})
+	if pole.Sub(eclipticZ).Len() > 1e-12 {
+		t.Fatalf("earth is not upright, its pole is %v", pole)
+	}
+	node := func() mgl64.Vec3 {
+		r := ps[1].Position.Sub(ps[0].Position)
+		return pole.Cross(r.Cross(ps[1].Velocity.Sub(ps[0].Velocity)))
+	}
+	n0 := node()
+	for i := 0; i < int(days*secondsPerDay/h); i++ {
+		stepParticleSystem(&w, d.Derivative, h, &ps)
+	}
+	n1 := node()
+
+	got := math.Atan2(n0.Cross(n1).Dot(pole), n0.Dot(n1))
+	n := math.Sqrt(mu / (a * a * a))
+	want := -1.5 * n * j2 * (radius / a) * (radius / a) * math.Cos(incl) / ((1 - e*e) * (1 - e*e)) * days * secondsPerDay
+	if math.Abs(got-want) > 0.01*math.Abs(want) {
+		t.Errorf("node moved by %.4f rad in %d days, want %.4f rad", got, days, want)
+	}
+}
+
+// The planet dynamics leave out the belts, which do not act on the planets, so they agree with
+// the dynamics of the whole system on the planets.
+func TestPlanetDynamics(t *testing.T) {
+	c := Config{
+		Bodies: []Celestialbody{
+			{Name: "sun", Mass: solarMass, Diameter: 1.3927e9, Luminosity: 3.828e26},
+			{Name: "earth", Mass: 5.9722e24, Diameter: 1.2756e7, J2: 1.08263e-3, Atmosphere: &Atmosphere{1.225, 8500}, Tides: &Tides{Love: 0.3, TimeLag: 600}},
+			{Name: "satellite", Mass: 1e3, Tracer: true, Area: 10, DragCoefficient: 2.2, Torque: []float64{0, 1, 0}},
+		},
+		Belts: []Belt{{Name: "dust", Count: 2, Diameter: 1e-4, RadiationPressure: true}},
+	}
+	l := Layout{Range{0, 3}, []Range{{3, 5}}}
+	ps := make(ParticleSystem, 5)
+	for i := range ps {
+		ps[i] = Particle{Position: mgl64.Vec3{1.496e11, 0, float64(i) * 1e6}, Velocity: mgl64.Vec3{0, 0, 3e4}, Mass: 1e-9, Tracer: true, Orientation: mgl64.QuatIdent()}
+	}
+	ps[0] = newParticle(c.Bodies[0])
+	ps[1].Mass, ps[1].Tracer, ps[1].Orientation = c.Bodies[1].Mass, false, upright
+	ps[2].Position, ps[2].Mass = ps[1].Position.Add(mgl64.Vec3{7e6, 0, 0}), c.Bodies[2].Mass
+	bodies := make([]Body, len(ps))
+	bodies[2].Inertia = mgl64.Diag3(mgl64.Vec3{30, 70, 50})
+
+	whole, planets := NewDynamics(c, ps, bodies, l, siConstants), PlanetDynamics(c, ps, bodies, l, siConstants)
+	dy := make(ParticleSystem, len(ps))
+	whole.Derivative(&ps, &dy)
+	y := ps[:3]
+	dp := make(ParticleSystem, len(y))
+	planets.Derivative(&y, &dp)
+	for i := range dp {
+		if dp[i] != dy[i] {
+			t.Errorf("body %d: %v with the planet dynamics, %v with the whole system", i, dp[i], dy[i])
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+const (
+	eventLogPath       = "events.log"
+	eventTimeTolerance = 1e-3 // seconds
+	eventMaxIterations = 100
+	eventHistory       = 5 // occurrences kept for display
+)
+
+// Event is a function of the state whose zero crossings mark the event. A crossing is
+// reported with the description of its direction, directions without a description are ignored.
+type Event struct {
+	F         func(ps ParticleSystem) float64
+	Bodies    []int                        // the particles F reads, all of them if empty
+	Rising    string                       // description of a crossing from negative to positive
+	Falling   string                       // description of a crossing from positive to negative
+	Condition func(ps ParticleSystem) bool // optional, the crossing is only reported if it holds at the event
+}
+
+// Occurrence is an event located between two steps.
+type Occurrence struct {
+	Time   float64
+	Event  *Event
+	Rising bool
+	State  ParticleSystem // interpolated to the time of the event
+}
+
+func (o *Occurrence) Description() string {
+	if o.Rising {
+		return o.Event.Rising
+	}
+	return o.Event.Falling
+}
+
+func (o *Occurrence) String() string {
+	return fmt.Sprintf("day %.3f: %s", days(o.Time), o.Description())
+}
+
+// Detector evaluates the events after every step and locates their exact time by root finding
+// on the interpolated motion between the steps.
+type Detector struct {
+	events   []*Event
+	handlers []func(o Occurrence)
+	last     ParticleSystem // state of the previous step
+	scratch  ParticleSystem // interpolated bodies of the event being located
+	values   []float64      // of the events at the previous step
+	time     float64
+	Recent   []Occurrence // the latest occurrences, oldest first
+}
+
+func NewDetector() *Detector {
+	return &Detector{}
+}
+
+func (d *Detector) Add(e *Event) {
+	d.events = append(d.events, e)
+	d.values = append(d.values, math.NaN())
+}
+
+// Registers a callback that is called with every occurrence, in order of time.
+func (d *Detector) Subscribe(h func(o Occurrence)) {
+	d.handlers = append(d.handlers, h)
+}
+
+// Cubic hermite interpolation of positions and velocities of the particles in bodies between a and b,
+// which are h apart, at the fraction s. Everything else is taken from a, all particles are interpolated if bodies is empty.
+func interpolateSystem(a, b, r ParticleSystem, bodies []int, h, s float64) {
+	s2, s3 := s*s, s*s*s
+	h00, h10, h01, h11 := 2*s3-3*s2+1, s3-2*s2+s, -2*s3+3*s2, s3-s2
+	d00, d10, d01, d11 := 6*s2-6*s, 3*s2-4*s+1, -6*s2+6*s, 3*s2-2*s
+	interpolate := func(i int) {
+		p0, p1, v0, v1 := a[i].Position, b[i].Position, a[i].Velocity, b[i].Velocity
+		r[i] = a[i]
+		r[i].Position = p0.Mul(h00).Add(v0.Mul(h * h10)).Add(p1.Mul(h01)).Add(v1.Mul(h * h11))
+		r[i].Velocity = p0.Mul(d00 / h).Add(v0.Mul(d10)).Add(p1.Mul(d01 / h)).Add(v1.Mul(d11))
+	}
+	if len(bodies) == 0 {
+		for i := range r {
+			interpolate(i)
+		}
+	}
+	for _, i := range bodies {
+		interpolate(i)
+	}
+}
+
+// Bisects the crossing of e between the last and the current state. Only the bodies of the event are
+// interpolated while bisecting, the whole state once at the crossing.
+func (d *Detector) locate(e *Event, ps ParticleSystem, t float64, g0 float64) (float64, ParticleSystem) {
+	h := t - d.time
+	lo, hi := 0.0, 1.0
+	if len(d.scratch) != len(ps) {
+		d.scratch = make(ParticleSystem, len(ps))
+	}
+	for i := 0; i < eventMaxIterations && (hi-lo)*h > units.FromSI(eventTimeTolerance, dimTime); i++ {
+		mid := (lo + hi) / 2
+		interpolateSystem(d.last, ps, d.scratch, e.Bodies, h, mid)
+		if (e.F(d.scratch) < 0) == (g0 < 0) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	r := make(ParticleSystem, len(ps))
+	interpolateSystem(d.last, ps, r, nil, h, hi)
+	return d.time + hi*h, r
+}
+
+// Forgets the previous state, e.g. after the velocities jumped, so nothing is located across the jump.
+func (d *Detector) Reset() {
+	d.last = d.last[:0]
+}
+
+// Checks the events against the state ps at the simulated time t, which follows the previous call.
+// It is called after every integration step, as interpolating over longer intervals would miss crossings.
+func (d *Detector) Observe(ps ParticleSystem, t float64) {
+	var found []Occurrence
+	for k, e := range d.events {
+		g0, g1 := d.values[k], e.F(ps)
+		d.values[k] = g1
+		if len(d.last) == 0 || math.IsNaN(g0) || t <= d.time {
+			continue
+		}
+		rising := g0 < 0 && g1 >= 0
+		falling := g0 > 0 && g1 <= 0
+		if (rising && e.Rising == "") || (falling && e.Falling == "") || !(rising || falling) {
+			continue
+		}
+		tr, state := d.locate(e, ps, t, g0)
+		if e.Condition == nil || e.Condition(state) {
+			found = append(found, Occurrence{tr, e, rising, state})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Time < found[j].Time })
+	for _, o := range found {
+		d.Emit(o)
+	}
+	d.last = append(d.last[:0], ps...)
+	d.time = t
+}
+
+// Passes o to the subscribers and keeps it with the recent occurrences. Used for the located
+// events and for occurrences found elsewhere, such as the crossings of a sphere of influence.
+func (d *Detector) Emit(o Occurrence) {
+	for _, h := range d.handlers {
+		h(o)
+	}
+	d.Recent = append(d.Recent, o)
+	if len(d.Recent) > eventHistory {
+		d.Recent = d.Recent[1:]
+	}
+}
+
+// Radial velocity of a relative to b, which rises through zero at the periapsis and falls at the apoapsis.
+func radialVelocity(ps ParticleSystem, a, b int) float64 {
+	return ps[a].Position.Sub(ps[b].Position).Dot(ps[a].Velocity.Sub(ps[b].Velocity))
+}
+
+func PeriapsisEvent(a, b int, bodies []Body) *Event {
+	return &Event{
+		F:      func(ps ParticleSystem) float64 { return radialVelocity(ps, a, b) },
+		Bodies: []int{a, b},
+		Rising: fmt.Sprintf("periapsis of %s around %s", bodies[a].Name, bodies[b].Name),
+	}
+}
+
+func ApoapsisEvent(a, b int, bodies []Body) *Event {
+	return &Event{
+		F:       func(ps ParticleSystem) float64 { return radialVelocity(ps, a, b) },
+		Bodies:  []int{a, b},
+		Falling: fmt.Sprintf("apoapsis of %s around %s", bodies[a].Name, bodies[b].Name),
+	}
+}
+
+// Closest approach of a and b, reported only if they come nearer than distance.
+func ApproachEvent(a, b int, distance float64, bodies []Body) *Event {
+	return &Event{
+		F:      func(ps ParticleSystem) float64 { return radialVelocity(ps, a, b) },
+		Bodies: []int{a, b},
+		Rising: fmt.Sprintf("close approach of %s and %s", bodies[a].Name, bodies[b].Name),
+		Condition: func(ps ParticleSystem) bool {
+			return ps[a].Position.Sub(ps[b].Position).Len() < distance
+		},
+	}
+}
+
+// Descent of a below the surface of b with the given radius.
+func ReentryEvent(a, b int, radius float64, bodies []Body) *Event {
+	return &Event{
+		F: func(ps ParticleSystem) float64 {
+			return ps[a].Position.Sub(ps[b].Position).Len() - radius
+		},
+		Bodies:  []int{a, b},
+		Falling: fmt.Sprintf("re-entry of %s into %s", bodies[a].Name, bodies[b].Name),
+	}
+}
+
+// Distance of the surface of the occulter from the line of sight between observer and source,
+// negative while the line of sight is blocked.
+func lineOfSight(ps ParticleSystem, observer, source, occulter int, radius float64) float64 {
+	a, b, c := ps[observer].Position, ps[source].Position, ps[occulter].Position
+	ab := b.Sub(a)
+	s := mgl64.Clamp(c.Sub(a).Dot(ab)/ab.LenSqr(), 0, 1)
+	return a.Add(ab.Mul(s)).Sub(c).Len() - radius
+}
+
+// Occultation of the source as seen from the observer, an eclipse if the source is luminous.
+func EclipseEvent(observer, source, occulter int, bodies []Body) *Event {
+	name := fmt.Sprintf("eclipse of %s by %s seen from %s", bodies[source].Name, bodies[occulter].Name, bodies[observer].Name)
+	return &Event{
+		F: func(ps ParticleSystem) float64 {
+			return lineOfSight(ps, observer, source, occulter, bodies[occulter].Radius)
+		},
+		Bodies:  []int{observer, source, occulter},
+		Rising:  name + " ends",
+		Falling: name + " begins",
+	}
+}
+
+// Crossing of the plane through the body origin with the given normal.
+func PlaneEvent(a, origin int, normal mgl64.Vec3, bodies []Body) *Event {
+	name := fmt.Sprintf("%s crosses the plane through %s", bodies[a].Name, bodies[origin].Name)
+	return &Event{
+		F:       func(ps ParticleSystem) float64 { return ps[a].Position.Sub(ps[origin].Position).Dot(normal) },
+		Bodies:  []int{a, origin},
+		Rising:  name + " along the normal",
+		Falling: name + " against the normal",
+	}
+}
+
+// EventConfig selects a built-in event. Body is the moving body, Other is its primary, the second
+// body of an approach, the occulted source of an eclipse or the origin of a plane.
+type EventConfig struct {
+	Kind     string // periapsis, apoapsis, approach, eclipse or plane
+	Body     string
+	Other    string
+	Occulter string
+	Distance float64   // of an approach
+	Normal   []float64 // of a plane, the ecliptic if not set
+}
+
+func newEvent(c EventConfig, bodies []Body, prim []int) (*Event, error) {
+	index := func(name string) int {
+		for i, b := range bodies {
+			if b.Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+	a, b := index(c.Body), index(c.Other)
+	if a == -1 {
+		return nil, fmt.Errorf("%s event of unknown body %q", c.Kind, c.Body)
+	}
+	if c.Other == "" {
+		b = prim[a]
+	}
+	if b == -1 {
+		return nil, fmt.Errorf("%s event of %s needs another body", c.Kind, c.Body)
+	}
+	switch c.Kind {
+	case "periapsis":
+		return PeriapsisEvent(a, b, bodies), nil
+	case "apoapsis":
+		return ApoapsisEvent(a, b, bodies), nil
+	case "approach":
+		return ApproachEvent(a, b, c.Distance, bodies), nil
+	case "eclipse":
+		o := index(c.Occulter)
+		if o == -1 {
+			return nil, fmt.Errorf("eclipse event of %s by unknown body %q", c.Body, c.Occulter)
+		}
+		return EclipseEvent(a, b, o, bodies), nil
+	case "plane":
+		n := eclipticZ
+		if len(c.Normal) == 3 {
+			n = mgl64.Vec3{c.Normal[0], c.Normal[1], c.Normal[2]}.Normalize()
+		}
+		return PlaneEvent(a, b, n, bodies), nil
+	}
+	return nil, fmt.Errorf("unknown event kind %q", c.Kind)
+}
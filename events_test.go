@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// A planet starting at apoapsis passes its periapsis after half a period. Locating the event
+// on its bodies alone finds the same time and state as interpolating the whole system.
+func TestLocatePeriapsis(t *testing.T) {
+	const (
+		a = 1.496e11
+		e = 0.3
+		h = secondsPerDay
+	)
+	sun := Particle{Mass: solarMass, Orientation: mgl64.QuatIdent()}
+	planet := Particle{Mass: 5.97e24, Orientation: mgl64.QuatIdent()}
+	mu := G * (sun.Mass + planet.Mass)
+	planet.Position = mgl64.Vec3{a * (1 + e), 0, 0}
+	planet.Velocity = mgl64.Vec3{0, 0, math.Sqrt(mu * (1 - e) / (a * (1 + e)))}
+	bodies := []Body{{Name: "sun"}, {Name: "planet"}, {Name: "rock"}}
+	rock := Particle{Position: mgl64.Vec3{-3e11, 0, 0}, Velocity: mgl64.Vec3{0, 0, 2e4}, Tracer: true, Orientation: mgl64.QuatIdent()}
+
+	focused := PeriapsisEvent(1, 0, bodies)
+	whole := *focused
+	whole.Bodies = nil
+	var found []Occurrence
+	d := NewDetector()
+	d.Add(focused)
+	d.Add(&whole)
+	d.Subscribe(func(o Occurrence) { found = append(found, o) })
+
+	ps := ParticleSystem{sun, planet, rock}
+	dyn := NewDynamics(Config{}, ps, nil, Layout{}, siConstants)
+	w := newRK4Workspace(len(ps))
+	period := 2 * math.Pi * math.Sqrt(a*a*a/mu)
+	simTime := 0.0
+	d.Observe(ps, simTime)
+	for simTime < 0.75*period {
+		stepParticleSystem(&w, dyn.Derivative, h, &ps)
+		simTime += h
+		d.Observe(ps, simTime)
+	}
+
+	if len(found) != 2 {
+		t.Fatalf("%d occurrences, want 2", len(found))
+	}
+	if dt := found[0].Time - period/2; math.Abs(dt) > 60 {
+		t.Errorf("periapsis %v s off", dt)
+	}
+	if found[0].Time != found[1].Time {
+		t.Errorf("located at %v on its bodies, at %v on the whole system", found[0].Time, found[1].Time)
+	}
+	for i := range ps {
+		if found[0].State[i] != found[1].State[i] {
+			t.Errorf("state of body %d differs: %v and %v", i, found[0].State[i], found[1].State[i])
+		}
+	}
+}
+
+// At a time warp where a frame spans several orbits, every periapsis is still found.
+func TestEventsAtWarp(t *testing.T) {
+	const (
+		a = 3.844e8
+		e = 0.2
+	)
+	earth := Particle{Mass: 5.9722e24, Orientation: mgl64.QuatIdent()}
+	moon := Particle{Mass: 7.342e22, Orientation: mgl64.QuatIdent()}
+	mu := G * (earth.Mass + moon.Mass)
+	moon.Position = mgl64.Vec3{a * (1 + e), 0, 0}
+	moon.Velocity = mgl64.Vec3{0, 0, math.Sqrt(mu * (1 - e) / (a * (1 + e)))}
+	rock := Particle{Position: mgl64.Vec3{-1e8, 0, 0}, Velocity: mgl64.Vec3{0, 0, 2e3}, Tracer: true, Orientation: mgl64.QuatIdent()}
+	ps := ParticleSystem{earth, moon, rock}
+	bodies := []Body{{Name: "earth"}, {Name: "moon"}, {Name: "rock"}}
+	prim := []int{-1, 0, 0}
+
+	var found []Occurrence
+	d := NewDetector()
+	d.Add(PeriapsisEvent(1, 0, bodies))
+	d.Subscribe(func(o Occurrence) { found = append(found, o) })
+	s, err := NewSchedule(nil, ps, 0, bodies, prim, NewDynamics(Config{}, ps, bodies, Layout{}, siConstants))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	period := 2 * math.Pi * math.Sqrt(a*a*a/mu)
+	frame := 3.5 * period
+	w := newRK4Workspace(2)
+	d.Observe(ps, 0)
+	for i := 0; i < 4; i++ {
+		if err := warpStep(s, &w, ps, 2, prim, float64(i)*frame, frame, 3600, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(found) != 14 {
+		t.Fatalf("%d periapses in 14 orbits", len(found))
+	}
+	for k, o := range found {
+		if dt := o.Time - (float64(k)+0.5)*period; math.Abs(dt) > 60 {
+			t.Errorf("periapsis %d off by %v s", k, dt)
+		}
+	}
+}
+
+// Crossings of a sphere of influence reach the subscribers and the recent occurrences like located events.
+func TestSOICrossingOccurrence(t *testing.T) {
+	bodies := []Body{{Name: "sun"}, {Name: "earth"}, {Name: "probe"}}
+	ps := ParticleSystem{
+		{Mass: solarMass, Orientation: mgl64.QuatIdent()},
+		{Position: mgl64.Vec3{1.496e11, 0, 0}, Mass: 5.97e24, Orientation: mgl64.QuatIdent()},
+		{Position: mgl64.Vec3{1.496e11 + 1e8, 0, 0}, Mass: 1e3, Tracer: true, Orientation: mgl64.QuatIdent()},
+	}
+	s := NewSOITracker(ps, []int{-1, 0, 1})
+	if s.Current[2] != 1 {
+		t.Fatalf("probe starts around %d", s.Current[2])
+	}
+
+	var found []Occurrence
+	d := NewDetector()
+	d.Subscribe(func(o Occurrence) { found = append(found, o) })
+	ps[2].Position = mgl64.Vec3{1.496e11 + 1e10, 0, 0}
+	for _, c := range s.Update(ps, 100) {
+		d.Emit(c.Occurrence(ps, bodies))
+	}
+
+	want := "day 0.001: probe left the sphere of influence of earth for sun"
+	if len(found) != 1 || found[0].String() != want {
+		t.Fatalf("subscribers got %v, want %q", found, want)
+	}
+	if len(d.Recent) != 1 || d.Recent[0].Time != 100 {
+		t.Errorf("recent occurrences %v", d.Recent)
+	}
+}
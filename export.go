@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+)
+
+const exportPath = "export.csv"
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'e', -1, 64)
+}
+
+// Writes the state in the frame fs and the osculating elements of every body as csv, in SI units.
+func exportCSV(w io.Writer, ps ParticleSystem, bodies []Body, prim []int, fs *FrameState, k *Constants) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"name", "primary",
+		"x", "y", "z", "vx", "vy", "vz", "mass",
+		"a", "e", "i", "node", "periapsis", "anomaly", "period",
+	})
+	for i, p := range ps {
+		row := []string{bodies[i].Name, ""}
+		for _, f := range fs.Position(p.Position) {
+			row = append(row, formatFloat(units.ToSI(f, dimLength)))
+		}
+		for _, f := range fs.Velocity(p.Position, p.Velocity) {
+			row = append(row, formatFloat(units.ToSI(f, dimSpeed)))
+		}
+		row = append(row, formatFloat(units.ToSI(p.Mass, dimMass)))
+
+		if prim[i] >= 0 {
+			row[1] = bodies[prim[i]].Name
+			o := p.Elements(&ps[prim[i]], k)
+			a, t := units.ToSI(o.SemiMajorAxis, dimLength), units.ToSI(o.Period, dimTime)
+			for _, f := range []float64{a, o.Eccentricity, o.Inclination, o.Node, o.Periapsis, o.TrueAnomaly, t} {
+				row = append(row, formatFloat(f))
+			}
+		} else {
+			row = append(row, "", "", "", "", "", "", "")
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportFile(path string, ps ParticleSystem, bodies []Body, prim []int, fs *FrameState, k *Constants) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return exportCSV(f, ps, bodies, prim, fs, k)
+}
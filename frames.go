@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+type FrameKind int
+
+const (
+	FrameInertial    FrameKind = iota // the frame the system is integrated in
+	FrameBarycentric                  // moves with the center of mass of the massive bodies
+	FrameBody                         // moves with the primary
+	FrameSynodic                      // rotates with primary and secondary, which stay on its x axis
+	frameKinds
+)
+
+var frameKindNames = [frameKinds]string{"inertial", "barycentric", "body", "synodic"}
+
+func (k FrameKind) String() string {
+	if k < 0 || k >= frameKinds {
+		return fmt.Sprintf("FrameKind(%d)", int(k))
+	}
+	return frameKindNames[k]
+}
+
+func (k *FrameKind) UnmarshalText(text []byte) error {
+	for i, n := range frameKindNames {
+		if n == string(text) {
+			*k = FrameKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown frame %q", text)
+}
+
+type FrameConfig struct {
+	Kind      FrameKind
+	Primary   string // center of the body frame, first body of the synodic frame
+	Secondary string // second body of the synodic frame
+}
+
+// Frame is a reference frame defined by the bodies of the system.
+type Frame struct {
+	Kind      FrameKind
+	Primary   int
+	Secondary int
+}
+
+func newFrame(c FrameConfig, bodies []Body) (Frame, error) {
+	f := Frame{c.Kind, -1, -1}
+	for i, b := range bodies {
+		if b.Name == c.Primary {
+			f.Primary = i
+		}
+		if b.Name == c.Secondary {
+			f.Secondary = i
+		}
+	}
+	if (c.Primary != "" && f.Primary == -1) || (c.Secondary != "" && f.Secondary == -1) {
+		return f, fmt.Errorf("frame of unknown bodies %q and %q", c.Primary, c.Secondary)
+	}
+	if (f.Kind == FrameBody || f.Kind == FrameSynodic) && f.Primary == -1 {
+		return f, fmt.Errorf("%s frame needs a primary", f.Kind)
+	}
+	if f.Kind == FrameSynodic && f.Secondary == -1 {
+		return f, fmt.Errorf("synodic frame needs a secondary")
+	}
+	return f, nil
+}
+
+// FrameState is a frame at one moment: its origin, its axes in world coordinates and its rotation rate.
+type FrameState struct {
+	origin   mgl64.Vec3
+	velocity mgl64.Vec3
+	axes     mgl64.Mat3 // columns are the axes of the frame
+	omega    mgl64.Vec3 // angular velocity in world coordinates
+}
+
+// Center of mass of the given bodies.
+func barycenter(ps ParticleSystem, indices []int) (mgl64.Vec3, mgl64.Vec3) {
+	var p, v mgl64.Vec3
+	var m float64
+	for _, i := range indices {
+		p = p.Add(ps[i].Position.Mul(ps[i].Mass))
+		v = v.Add(ps[i].Velocity.Mul(ps[i].Mass))
+		m += ps[i].Mass
+	}
+	return p.Mul(1 / m), v.Mul(1 / m)
+}
+
+// Switches to the next kind of frame for which the bodies are configured.
+func (f *Frame) Next() {
+	for {
+		f.Kind = (f.Kind + 1) % frameKinds
+		if (f.Kind != FrameBody || f.Primary != -1) && (f.Kind != FrameSynodic || (f.Primary != -1 && f.Secondary != -1)) {
+			return
+		}
+	}
+}
+
+func (f *Frame) At(ps ParticleSystem) FrameState {
+	s := FrameState{axes: mgl64.Ident3()}
+	switch f.Kind {
+	case FrameBarycentric:
+		s.origin, s.velocity = barycenter(ps, massiveIndices(ps))
+	case FrameBody:
+		s.origin, s.velocity = ps[f.Primary].Position, ps[f.Primary].Velocity
+	case FrameSynodic:
+		a, b := &ps[f.Primary], &ps[f.Secondary]
+		s.origin, s.velocity = barycenter(ps, []int{f.Primary, f.Secondary})
+		r := b.Position.Sub(a.Position)
+		v := b.Velocity.Sub(a.Velocity)
+		x := r.Normalize()
+		z := r.Cross(v).Normalize()
+		s.axes = mgl64.Mat3FromCols(x, z.Cross(x), z)
+		s.omega = r.Cross(v).Mul(1 / r.LenSqr())
+	}
+	return s
+}
+
+// Coordinates of the world position p in the frame.
+func (s *FrameState) Position(p mgl64.Vec3) mgl64.Vec3 {
+	return s.axes.Transpose().Mul3x1(p.Sub(s.origin))
+}
+
+// Velocity in the frame of a body at the world position p with the world velocity v.
+func (s *FrameState) Velocity(p, v mgl64.Vec3) mgl64.Vec3 {
+	return s.axes.Transpose().Mul3x1(v.Sub(s.velocity).Sub(s.omega.Cross(p.Sub(s.origin))))
+}
+
+// World position of the frame coordinates p.
+func (s *FrameState) World(p mgl64.Vec3) mgl64.Vec3 {
+	return s.origin.Add(s.axes.Mul3x1(p))
+}
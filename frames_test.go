@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// Sun and earth on a circular orbit, in SI units.
+func circularPair() ParticleSystem {
+	const a = 1.496e11
+	sun := Particle{Mass: solarMass, Orientation: mgl64.QuatIdent()}
+	earth := Particle{Mass: 5.9722e24, Orientation: mgl64.QuatIdent()}
+	mu := G * (sun.Mass + earth.Mass)
+	// at rest in the barycenter
+	v := mgl64.Vec3{0, 0, math.Sqrt(mu / a)}
+	sun.Velocity = v.Mul(-earth.Mass / (sun.Mass + earth.Mass))
+	earth.Position = mgl64.Vec3{a, 0, 0}
+	earth.Velocity = v.Add(sun.Velocity)
+	return ParticleSystem{sun, earth}
+}
+
+// A circular orbit stands still in the synodic frame: both bodies stay on its x axis at rest,
+// and the coordinates convert back to the world positions.
+func TestSynodicFrame(t *testing.T) {
+	const h = secondsPerDay
+	ps := circularPair()
+	f := Frame{FrameSynodic, 0, 1}
+	d := NewDynamics(Config{}, ps, nil, Layout{}, siConstants)
+	w := newRK4Workspace(len(ps))
+	start := f.At(ps)
+	x0 := [2]mgl64.Vec3{start.Position(ps[0].Position), start.Position(ps[1].Position)}
+	for i := 0; i < 365; i++ {
+		stepParticleSystem(&w, d.Derivative, h, &ps)
+		if i%30 != 0 {
+			continue
+		}
+		fs := f.At(ps)
+		for k := range ps {
+			p := fs.Position(ps[k].Position)
+			if p.Sub(x0[k]).Len() > 1e-6*x0[k].Len() || math.Abs(p[1]) > 1e-6*x0[1].Len() || math.Abs(p[2]) > 1e-6*x0[1].Len() {
+				t.Errorf("day %d: body %d at %v in the synodic frame, started at %v", i+1, k, p, x0[k])
+			}
+			if v := fs.Velocity(ps[k].Position, ps[k].Velocity); v.Len() > 1e-6*ps[1].Velocity.Len() {
+				t.Errorf("day %d: body %d moves with %v in the synodic frame", i+1, k, v)
+			}
+			if q := fs.World(p); q.Sub(ps[k].Position).Len() > 1e-12*ps[1].Position.Len() {
+				t.Errorf("day %d: body %d back at %v from %v", i+1, k, q, ps[k].Position)
+			}
+		}
+	}
+}
+
+// The predicted paths are in the frame like the trails, so the path of a circular orbit is a point in the synodic frame.
+func TestPathsInFrame(t *testing.T) {
+	ps := circularPair()
+	bodies := []Body{{Name: "sun"}, {Name: "earth"}}
+	s, err := NewSchedule(nil, ps, 0, bodies, []int{-1, 0}, NewDynamics(Config{}, ps, bodies, Layout{}, siConstants))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := Frame{FrameSynodic, 0, 1}
+	p := NewPredictor(PredictionConfig{100, defaultPredictionStep}, s, s.dynamics, &f)
+	p.Update(ps, 0)
+	for p.current == nil {
+		p.Update(ps, 0)
+	}
+
+	fs := f.At(ps)
+	want := fs.Position(ps[1].Position)
+	paths := p.Paths(0)
+	for k, q := range paths[1] {
+		if q.Sub(want).Len() > 1e-6*want.Len() {
+			t.Fatalf("earth predicted at %v in the synodic frame after %d steps, want %v", q, k, want)
+		}
+	}
+}
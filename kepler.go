@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+	"github.com/luisgargitter/numerics"
+)
+
+const (
+	keplerTolerance     = 1e-12
+	keplerMaxIterations = 64
+)
+
+// Stumpff functions C(z) and S(z).
+func stumpff(z float64) (float64, float64) {
+	switch {
+	case math.Abs(z) < 1e-3:
+		// series expansion, the closed forms cancel badly near zero
+		c := 1.0/2 - z/24 + z*z/720 - z*z*z/40320
+		s := 1.0/6 - z/120 + z*z/5040 - z*z*z/362880
+		return c, s
+	case z > 0:
+		sz := math.Sqrt(z)
+		return (1 - math.Cos(sz)) / z, (sz - math.Sin(sz)) / (sz * sz * sz)
+	default:
+		sz := math.Sqrt(-z)
+		return (math.Cosh(sz) - 1) / -z, (math.Sinh(sz) - sz) / (sz * sz * sz)
+	}
+}
+
+// Advances the relative state (r, v) of a two-body orbit with gravitational parameter mu by dt
+// using the universal variable formulation, which covers elliptic, parabolic and hyperbolic orbits.
+func KeplerPropagate(r, v mgl64.Vec3, mu, dt float64) (mgl64.Vec3, mgl64.Vec3, error) {
+	if dt == 0 {
+		return r, v, nil
+	}
+	r0 := r.Len()
+	sqrtMu := math.Sqrt(mu)
+	vr0 := r.Dot(v) / r0
+	alpha := 2/r0 - v.LenSqr()/mu // reciprocal of the semi-major axis
+
+	if alpha > 0 {
+		// whole revolutions do not change the state
+		period := 2 * math.Pi / (sqrtMu * math.Pow(alpha, 1.5))
+		dt = math.Mod(dt, period)
+	}
+
+	// initial guesses after Vallado, then newton iteration on the universal kepler equation
+	var x float64
+	switch {
+	case alpha*r0 > 1e-9:
+		x = sqrtMu * alpha * dt
+	case alpha*r0 < -1e-9:
+		a := 1 / alpha
+		sign := math.Copysign(1, dt)
+		x = sign * math.Sqrt(-a) * math.Log(-2*mu*alpha*dt/(r.Dot(v)+sign*math.Sqrt(-mu*a)*(1-r0*alpha)))
+	default:
+		p := r.Cross(v).LenSqr() / mu
+		s := math.Atan(1/(3*math.Sqrt(mu/(p*p*p))*dt)) / 2
+		w := math.Atan(math.Cbrt(math.Tan(s)))
+		x = math.Sqrt(p) * 2 / math.Tan(2*w)
+	}
+	converged := false
+	for i := 0; i < keplerMaxIterations; i++ {
+		z := alpha * x * x
+		c, s := stumpff(z)
+		f := r0*vr0/sqrtMu*x*x*c + (1-alpha*r0)*x*x*x*s + r0*x - sqrtMu*dt
+		df := r0*vr0/sqrtMu*x*(1-z*s) + (1-alpha*r0)*x*x*c + r0
+		step := f / df
+		x -= step
+		if math.Abs(step) <= keplerTolerance*math.Max(1, math.Abs(x)) {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return r, v, fmt.Errorf("kepler propagation did not converge (dt = %e)", dt)
+	}
+
+	z := alpha * x * x
+	c, s := stumpff(z)
+	f := 1 - x*x/r0*c
+	g := dt - x*x*x/sqrtMu*s
+	rn := r.Mul(f).Add(v.Mul(g))
+	rl := rn.Len()
+	df := sqrtMu / (rl * r0) * (z*x*s - x)
+	dg := 1 - x*x/rl*c
+	vn := r.Mul(df).Add(v.Mul(dg))
+
+	return rn, vn, nil
+}
+
+// Advances the isolated pair a, b by dt. The barycenter moves uniformly and
+// the relative motion follows the exact keplerian solution.
+func KeplerTwoBody(a, b Particle, dt float64, k *Constants) (Particle, Particle, error) {
+	m := a.Mass + b.Mass
+	cp := a.Position.Mul(a.Mass).Add(b.Position.Mul(b.Mass)).Mul(1 / m)
+	cv := a.Velocity.Mul(a.Mass).Add(b.Velocity.Mul(b.Mass)).Mul(1 / m)
+
+	r, v, err := KeplerPropagate(b.Position.Sub(a.Position), b.Velocity.Sub(a.Velocity), k.G*m, dt)
+	if err != nil {
+		return a, b, err
+	}
+
+	cp = cp.Add(cv.Mul(dt))
+	a.Position = cp.Sub(r.Mul(b.Mass / m))
+	a.Velocity = cv.Sub(v.Mul(b.Mass / m))
+	b.Position = cp.Add(r.Mul(a.Mass / m))
+	b.Velocity = cv.Add(v.Mul(a.Mass / m))
+	return a, b, nil
+}
+
+// Moves body i along its unperturbed orbit around the primary by dt, leaving every other body untouched.
+// Only meaningful for light bodies whose perturbations over dt are negligible.
+func fastForward(ps ParticleSystem, i, primary int, dt float64, k *Constants) error {
+	p := &ps[i]
+	a := &ps[primary]
+	r, v, err := KeplerPropagate(p.Position.Sub(a.Position), p.Velocity.Sub(a.Velocity), p.Mu(a, k), dt)
+	if err != nil {
+		return err
+	}
+	p.Position = a.Position.Add(r)
+	p.Velocity = a.Velocity.Add(v)
+	return nil
+}
+
+// carrier moves the tracers after the first n bodies rigidly along with their primaries,
+// while only the first n bodies are integrated.
+type carrier struct {
+	ps       ParticleSystem
+	n        int
+	prim     []int
+	position []mgl64.Vec3 // relative to the primary
+	velocity []mgl64.Vec3
+	o        Observer // sees the whole system, if set
+}
+
+func newCarrier(ps ParticleSystem, n int, prim []int, o Observer) *carrier {
+	c := &carrier{ps, n, prim, make([]mgl64.Vec3, len(ps)-n), make([]mgl64.Vec3, len(ps)-n), o}
+	for i := n; i < len(ps); i++ {
+		if k := prim[i]; k >= 0 && k < n {
+			c.position[i-n] = ps[i].Position.Sub(ps[k].Position)
+			c.velocity[i-n] = ps[i].Velocity.Sub(ps[k].Velocity)
+		}
+	}
+	return c
+}
+
+func (c *carrier) place() {
+	for i := c.n; i < len(c.ps); i++ {
+		if k := c.prim[i]; k >= 0 && k < c.n {
+			c.ps[i].Position = c.ps[k].Position.Add(c.position[i-c.n])
+			c.ps[i].Velocity = c.ps[k].Velocity.Add(c.velocity[i-c.n])
+		}
+	}
+}
+
+func (c *carrier) Observe(_ ParticleSystem, t float64) {
+	if c.o != nil {
+		c.place()
+		c.o.Observe(c.ps, t)
+	}
+}
+
+func (c *carrier) Reset() {
+	if c.o != nil {
+		c.o.Reset()
+	}
+}
+
+// Advances ps by dt at a large time warp. The bodies before n are integrated through the schedule in
+// steps of at most h, the rest, the light tracers of the belts, follow their unperturbed orbits around
+// their primaries in one step each. The workspace has to fit the first n bodies. The optional observer
+// sees every step, with the tracers carried along by their primaries until they are moved at the end.
+func warpStep(s *Schedule, w *numerics.RK4Workspace[ParticleSystem], ps ParticleSystem, n int, prim []int, t, dt, h float64, o Observer) error {
+	c := newCarrier(ps, n, prim, o)
+	bodies := ps[:n]
+	steps := math.Ceil(math.Abs(dt) / h)
+	for j := 0.0; j < steps; j++ {
+		s.Step(w, &bodies, t+j*dt/steps, dt/steps, c)
+	}
+
+	c.place()
+	var err error
+	for i := n; i < len(ps); i++ {
+		k := prim[i]
+		if k < 0 || k >= n {
+			continue
+		}
+		if e := fastForward(ps, i, k, dt, &s.dynamics.Constants); e != nil && err == nil {
+			err = e
+		}
+	}
+	if o != nil {
+		// the tracers jump onto their orbits, which starts the next step
+		o.Observe(ps, t+dt)
+	}
+	return err
+}
+
+// Solves kepler's equation M = E - e sin E of an elliptic orbit and returns the true anomaly.
+func trueAnomalyFromMean(m, e float64) float64 {
+	E := m
+	if e > 0.8 {
+		E = math.Pi
+	}
+	for i := 0; i < keplerMaxIterations; i++ {
+		step := (E - e*math.Sin(E) - m) / (1 - e*math.Cos(E))
+		E -= step
+		if math.Abs(step) < keplerTolerance {
+			break
+		}
+	}
+	return 2 * math.Atan2(math.Sqrt(1+e)*math.Sin(E/2), math.Sqrt(1-e)*math.Cos(E/2))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// The analytic solution is the oracle for the numeric integration of the same two bodies.
+func TestKeplerTwoBodyAgainstRK4(t *testing.T) {
+	const (
+		year = 365.25 * secondsPerDay
+		h    = 600.0
+		r    = 1.496e11
+	)
+	sun := Particle{Mass: 1.989e30, Orientation: mgl64.QuatIdent()}
+	planet := 5.97e24
+	escape := math.Sqrt(2 * G * (sun.Mass + planet) / r)
+	newtonian := NewDynamics(Config{}, nil, nil, Layout{}, siConstants)
+	cases := []struct {
+		name  string
+		speed float64 // at the start, perpendicular to the radius
+	}{
+		{"elliptic", 0.8 * escape},
+		{"parabolic", escape},
+		{"hyperbolic", 1.3 * escape},
+	}
+	for _, c := range cases {
+		for _, dir := range []float64{1, -1} {
+			p := Particle{Position: mgl64.Vec3{r, 0, 0}, Velocity: mgl64.Vec3{0, 0.6 * c.speed, 0.8 * c.speed}, Mass: planet, Orientation: mgl64.QuatIdent()}
+			a, b, err := KeplerTwoBody(sun, p, dir*year, &siConstants)
+			if err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+
+			ps := ParticleSystem{sun, p}
+			w := newRK4Workspace(len(ps))
+			for i := 0; i < int(year/h); i++ {
+				stepParticleSystem(&w, newtonian.Derivative, dir*h, &ps)
+			}
+			stepParticleSystem(&w, newtonian.Derivative, dir*(year-h*math.Floor(year/h)), &ps)
+
+			if d := ps[1].Position.Sub(b.Position).Len(); d > 1e-6*b.Position.Len() {
+				t.Errorf("%s, direction %v: position off by %e m of %e m", c.name, dir, d, b.Position.Len())
+			}
+			if d := ps[1].Velocity.Sub(b.Velocity).Len(); d > 1e-6*b.Velocity.Len() {
+				t.Errorf("%s, direction %v: velocity off by %e m/s", c.name, dir, d)
+			}
+			if d := ps[0].Position.Sub(a.Position).Len(); d > 1e-6*b.Position.Len() {
+				t.Errorf("%s, direction %v: primary off by %e m", c.name, dir, d)
+			}
+		}
+	}
+}
+
+// Propagating forward and back again returns to the start.
+func TestKeplerPropagateReversible(t *testing.T) {
+	const mu = 3.986004418e14
+	r0 := mgl64.Vec3{7e6, 1e6, 0}
+	for _, v0 := range []mgl64.Vec3{{0, 0, 7000}, {0, 0, math.Sqrt(2 * mu / r0.Len())}, {0, 3000, 12000}} {
+		r1, v1, err := KeplerPropagate(r0, v0, mu, 1e5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r2, v2, err := KeplerPropagate(r1, v1, mu, -1e5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r2.Sub(r0).Len() > 1e-6*r0.Len() || v2.Sub(v0).Len() > 1e-6*v0.Len() {
+			t.Errorf("start %v %v, returned to %v %v", r0, v0, r2, v2)
+		}
+	}
+}
+
+// A tracer fast-forwarded at high warp ends up where the integration takes it.
+func TestWarpStep(t *testing.T) {
+	sun := Particle{Mass: 1.989e30, Orientation: mgl64.QuatIdent()}
+	planet := Particle{Position: mgl64.Vec3{1.496e11, 0, 0}, Velocity: mgl64.Vec3{0, 0, 29.8e3}, Mass: 5.97e24, Orientation: mgl64.QuatIdent()}
+	rock := Particle{Position: mgl64.Vec3{-4e11, 0, 0}, Velocity: mgl64.Vec3{0, 1e3, -18e3}, Mass: 1e15, Tracer: true, Orientation: mgl64.QuatIdent()}
+	prim := []int{-1, 0, 0}
+	s, err := NewSchedule(nil, nil, 0, nil, prim, NewDynamics(Config{}, nil, nil, Layout{}, siConstants))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const dt, h = 100 * secondsPerDay, 3600.0
+	warped := ParticleSystem{sun, planet, rock}
+	w := newRK4Workspace(2)
+	if err := warpStep(s, &w, warped, 2, prim, 0, dt, h, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	integrated := ParticleSystem{sun, planet, rock}
+	wi := newRK4Workspace(3)
+	for i := 0; i < int(dt/h); i++ {
+		s.Step(&wi, &integrated, float64(i)*h, h, nil)
+	}
+	for i := range integrated {
+		// the tracer misses the pull of the planet, which is far away
+		if d := warped[i].Position.Sub(integrated[i].Position).Len(); d > 1e-4*integrated[i].Position.Len() {
+			t.Errorf("body %d off by %e m", i, d)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+const lagrangeIterations = 50
+
+var lagrangeNames = [5]string{"L1", "L2", "L3", "L4", "L5"}
+
+// LagrangePoint is an equilibrium point of the restricted three-body problem of a primary and a secondary.
+type LagrangePoint struct {
+	Frame    mgl64.Vec3 // in the co-rotating frame: barycenter at the origin, secondary along +x, orbit normal along +z, in units of the separation
+	Position mgl64.Vec3 // inertial
+	Velocity mgl64.Vec3
+}
+
+// LagrangeConfig marks the lagrange points of a pair of bodies.
+type LagrangeConfig struct {
+	Primary   string
+	Secondary string
+}
+
+func lagrangeIndex(name string) (int, error) {
+	for i, n := range lagrangeNames {
+		if n == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown lagrange point %q", name)
+}
+
+// Position of a collinear point on the x axis of the co-rotating frame, found by newton iteration
+// from the guess x, where the gravity of both bodies balances the centrifugal force.
+func collinearPoint(mu, x float64) float64 {
+	for i := 0; i < lagrangeIterations; i++ {
+		d1 := x + mu
+		d2 := x - 1 + mu
+		a1, a2 := math.Abs(d1), math.Abs(d2)
+		f := x - (1-mu)*d1/(a1*a1*a1) - mu*d2/(a2*a2*a2)
+		df := 1 + 2*(1-mu)/(a1*a1*a1) + 2*mu/(a2*a2*a2)
+		dx := f / df
+		x -= dx
+		if math.Abs(dx) < 1e-15 {
+			break
+		}
+	}
+	return x
+}
+
+// The five lagrange points of the secondary b orbiting the primary a. The inertial states follow
+// the co-rotating frame, which also pulsates with the separation on eccentric orbits.
+func LagrangePoints(a, b *Particle) [5]LagrangePoint {
+	mu := b.Mass / (a.Mass + b.Mass)
+	cr := math.Cbrt(mu / 3)
+	frame := [5]mgl64.Vec3{
+		{collinearPoint(mu, 1-mu-cr), 0, 0},
+		{collinearPoint(mu, 1-mu+cr), 0, 0},
+		{collinearPoint(mu, -1-5*mu/12), 0, 0},
+		{0.5 - mu, math.Sqrt(3) / 2, 0}, // leading the secondary
+		{0.5 - mu, -math.Sqrt(3) / 2, 0},
+	}
+
+	r := b.Position.Sub(a.Position)
+	v := b.Velocity.Sub(a.Velocity)
+	d := r.Len()
+	ex := r.Normalize()
+	ez := r.Cross(v).Normalize()
+	ey := ez.Cross(ex)
+	omega := r.Cross(v).Mul(1 / (d * d))
+	radial := r.Dot(v) / (d * d)
+
+	m := a.Mass + b.Mass
+	cp := a.Position.Mul(a.Mass / m).Add(b.Position.Mul(b.Mass / m))
+	cv := a.Velocity.Mul(a.Mass / m).Add(b.Velocity.Mul(b.Mass / m))
+
+	var res [5]LagrangePoint
+	for i, f := range frame {
+		o := ex.Mul(f[0] * d).Add(ey.Mul(f[1] * d))
+		res[i] = LagrangePoint{f, cp.Add(o), cv.Add(o.Mul(radial)).Add(omega.Cross(o))}
+	}
+	return res
+}
+
+// Resolves the configured pairs to the indices of primary and secondary.
+func lagrangePairs(cs []LagrangeConfig, bodies []Body) ([][2]int, error) {
+	var r [][2]int
+	for _, c := range cs {
+		p, s := -1, -1
+		for i, b := range bodies {
+			if b.Name == c.Primary {
+				p = i
+			}
+			if b.Name == c.Secondary {
+				s = i
+			}
+		}
+		if p == -1 || s == -1 {
+			return nil, fmt.Errorf("lagrange points of unknown bodies %q and %q", c.Primary, c.Secondary)
+		}
+		r = append(r, [2]int{p, s})
+	}
+	return r, nil
+}
+
+// Current positions of the lagrange points of all pairs.
+func lagrangePositions(ps ParticleSystem, pairs [][2]int) []mgl64.Vec3 {
+	var r []mgl64.Vec3
+	for _, p := range pairs {
+		for _, l := range LagrangePoints(&ps[p[0]], &ps[p[1]]) {
+			r = append(r, l.Position)
+		}
+	}
+	return r
+}
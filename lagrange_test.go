@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// For a light secondary the collinear points close to it lie at the hill radius, with the
+// second order corrections of the series in the hill radius.
+func TestCollinearPointsHill(t *testing.T) {
+	for _, mu := range []float64{1e-6, 3e-6, 1e-9} {
+		primary := Particle{Mass: 1 - mu}
+		secondary := Particle{Position: mgl64.Vec3{1, 0, 0}, Velocity: mgl64.Vec3{0, 0, 1}, Mass: mu}
+		l := LagrangePoints(&primary, &secondary)
+		h := math.Cbrt(mu / 3)
+		for _, c := range []struct {
+			name      string
+			got, want float64
+		}{
+			{"L1", 1 - mu - l[0].Frame[0], h - h*h/3},
+			{"L2", l[1].Frame[0] - (1 - mu), h + h*h/3},
+		} {
+			if math.Abs(c.got-c.want) > h*h*h {
+				t.Errorf("mu %g: %s at %v from the secondary, hill radius %v gives %v", mu, c.name, c.got, h, c.want)
+			}
+		}
+	}
+}
+
+// A tracer placed at L4 of sun and jupiter stays 60° ahead of jupiter.
+func TestTrojanStaysAtL4(t *testing.T) {
+	const (
+		a      = 7.785e11
+		orbits = 5
+		h      = 5 * secondsPerDay
+	)
+	sun := Particle{Mass: solarMass, Orientation: mgl64.QuatIdent()}
+	jupiter := Particle{Mass: 1.898e27, Orientation: mgl64.QuatIdent()}
+	mu := G * (sun.Mass + jupiter.Mass)
+	jupiter.Position = mgl64.Vec3{a, 0, 0}
+	jupiter.Velocity = mgl64.Vec3{0, 0, math.Sqrt(mu / a)}
+	sun.Velocity = jupiter.Velocity.Mul(-jupiter.Mass / sun.Mass)
+	l4 := LagrangePoints(&sun, &jupiter)[3]
+	trojan := Particle{Position: l4.Position, Velocity: l4.Velocity, Mass: 1e3, Tracer: true, Orientation: mgl64.QuatIdent()}
+
+	ps := ParticleSystem{sun, jupiter, trojan}
+	d := NewDynamics(Config{}, ps, nil, Layout{}, siConstants)
+	w := newRK4Workspace(len(ps))
+	period := 2 * math.Pi * math.Sqrt(a*a*a/mu)
+	worst := 0.0
+	for i := 0; i < int(orbits*period/h); i++ {
+		stepParticleSystem(&w, d.Derivative, h, &ps)
+		m := ps[0].Mass + ps[1].Mass
+		center := ps[0].Position.Mul(ps[0].Mass / m).Add(ps[1].Position.Mul(ps[1].Mass / m))
+		r, p := ps[1].Position.Sub(center), ps[2].Position.Sub(center)
+		n := r.Cross(ps[1].Velocity.Sub(ps[0].Velocity)).Normalize()
+		ahead := math.Atan2(r.Cross(p).Dot(n), r.Dot(p)) * 180 / math.Pi
+		worst = math.Max(worst, math.Abs(ahead-60))
+	}
+	if worst > 3 {
+		t.Errorf("trojan strayed %.2f° from 60° ahead of jupiter", worst)
+	}
+}
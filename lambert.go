@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+const (
+	lambertTolerance     = 1e-9
+	lambertMaxIterations = 200
+)
+
+// Solves Lambert's problem: the velocities at r1 and r2 of the two-body orbit with gravitational
+// parameter mu that connects them in the time dt. The transfer runs in the sense of the normal n,
+// so it takes the long way around if r1 x r2 points against n. Only transfers of less than one
+// revolution are found. Uses the universal variable formulation with bisection after Vallado.
+func Lambert(r1, r2 mgl64.Vec3, dt, mu float64, n mgl64.Vec3) (mgl64.Vec3, mgl64.Vec3, error) {
+	if dt <= 0 {
+		return mgl64.Vec3{}, mgl64.Vec3{}, fmt.Errorf("lambert: transfer time %g is not positive", dt)
+	}
+	l1, l2 := r1.Len(), r2.Len()
+	cosNu := r1.Dot(r2) / (l1 * l2)
+	dm := 1.0
+	if r1.Cross(r2).Dot(n) < 0 {
+		dm = -1
+	}
+	a := dm * math.Sqrt(l1*l2*(1+cosNu))
+	if math.Abs(a) < 1e-12*math.Sqrt(l1*l2) {
+		return mgl64.Vec3{}, mgl64.Vec3{}, fmt.Errorf("lambert: positions are opposite, the transfer plane is undefined")
+	}
+
+	sqrtMu := math.Sqrt(mu)
+	// the time of flight grows monotonically with psi, from hyperbolic (negative)
+	// over parabolic (zero) to elliptic orbits approaching a full revolution (4 pi^2)
+	low, high := -4*math.Pi*math.Pi, 4*math.Pi*math.Pi
+	var y float64
+	for i := 0; ; i++ {
+		if i == lambertMaxIterations {
+			return mgl64.Vec3{}, mgl64.Vec3{}, fmt.Errorf("lambert: no convergence for a transfer time of %g", dt)
+		}
+		psi := (low + high) / 2
+		c, s := stumpff(psi)
+		y = l1 + l2 + a*(psi*s-1)/math.Sqrt(c)
+		if y < 0 {
+			// no solution this far into the hyperbolic range, the time of flight is too short
+			low = psi
+			continue
+		}
+		x := math.Sqrt(y / c)
+		t := (x*x*x*s + a*math.Sqrt(y)) / sqrtMu
+		if math.Abs(t-dt) < lambertTolerance*dt {
+			break
+		}
+		if t < dt {
+			low = psi
+		} else {
+			high = psi
+		}
+	}
+
+	// lagrange coefficients
+	f := 1 - y/l1
+	g := a * math.Sqrt(y/mu)
+	gdot := 1 - y/l2
+	v1 := r2.Sub(r1.Mul(f)).Mul(1 / g)
+	v2 := r2.Mul(gdot).Sub(r1).Mul(1 / g)
+	return v1, v2, nil
+}
+
+// Delta-v of both burns and the transfer time of a hohmann transfer between circular orbits
+// of radius r1 and r2.
+func Hohmann(r1, r2, mu float64) (float64, float64, float64) {
+	a := (r1 + r2) / 2
+	dv1 := math.Abs(math.Sqrt(mu/r1) * (math.Sqrt(r2/a) - 1))
+	dv2 := math.Abs(math.Sqrt(mu/r2) * (1 - math.Sqrt(r1/a)))
+	return dv1, dv2, math.Pi * math.Sqrt(a*a*a/mu)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// The transfer between two states of a propagated orbit has the velocities of that orbit,
+// the short and the long way around.
+func TestLambert(t *testing.T) {
+	const (
+		a = 1.496e11
+		e = 0.3
+	)
+	mu := G * solarMass
+	r1 := mgl64.Vec3{a * (1 - e), 0, 0}
+	v1 := mgl64.Vec3{0, 0, math.Sqrt(mu * (1 + e) / (a * (1 - e)))}
+	n := r1.Cross(v1)
+	period := 2 * math.Pi * math.Sqrt(a*a*a/mu)
+
+	for _, c := range []struct {
+		name     string
+		fraction float64
+	}{
+		{"short way", 0.3},
+		{"long way", 0.7},
+	} {
+		dt := c.fraction * period
+		r2, v2, err := KeplerPropagate(r1, v1, mu, dt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if long := r1.Cross(r2).Dot(n) < 0; long != (c.name == "long way") {
+			t.Fatalf("%s: the orbit does not go the %s", c.name, c.name)
+		}
+		tv1, tv2, err := Lambert(r1, r2, dt, mu, n)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if d := tv1.Sub(v1).Len(); d > 1e-6*v1.Len() {
+			t.Errorf("%s: departure velocity %v, want %v", c.name, tv1, v1)
+		}
+		if d := tv2.Sub(v2).Len(); d > 1e-6*v2.Len() {
+			t.Errorf("%s: arrival velocity %v, want %v", c.name, tv2, v2)
+		}
+	}
+
+	// opposite positions leave the plane of the transfer open
+	if _, _, err := Lambert(r1, r1.Mul(-1.5), period/2, mu, n); err == nil {
+		t.Error("no error for a transfer of 180°")
+	}
+}
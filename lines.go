@@ -0,0 +1,174 @@
+package main
+
+import (
+	"unsafe"
+
+	"github.com/go-gl/gl/v2.1/gl"
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+var (
+	trailColor      = [3]float32{0.6, 0.7, 1.0}
+	predictionColor = [3]float32{1.0, 0.8, 0.4}
+	markerColor     = [3]float32{0.4, 1.0, 0.5}
+)
+
+// size of the markers on the screen
+const markerPixels = 6.0
+
+// LineRenderer draws polylines in the same space as Scene.Draw.
+type LineRenderer struct {
+	program  uint32
+	uniforms Uniforms
+	colorU   int32
+	vao      uint32
+	vbo      uint32
+	buffer   [][4]float32 // position and fade of every vertex
+	firsts   []int32
+	counts   []int32
+}
+
+func NewLineRenderer() LineRenderer {
+	var r LineRenderer
+	program, err := newProgram(lineVertexShaderSource, lineFragmentShaderSource)
+	if err != nil {
+		panic(err)
+	}
+	r.program = program
+	r.uniforms = getUniforms(program)
+	r.colorU = gl.GetUniformLocation(program, gl.Str("color\x00"))
+
+	gl.GenVertexArrays(1, &r.vao)
+	gl.BindVertexArray(r.vao)
+	gl.GenBuffers(1, &r.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.vbo)
+	gl.VertexAttribPointer(0, 4, gl.FLOAT, false, int32(unsafe.Sizeof([4]float32{})), nil)
+	gl.EnableVertexAttribArray(0)
+	gl.BindVertexArray(0)
+
+	return r
+}
+
+func (r *LineRenderer) reset() {
+	r.buffer = r.buffer[:0]
+	r.firsts = r.firsts[:0]
+	r.counts = r.counts[:0]
+}
+
+func (r *LineRenderer) vertex(c *Camera, p mgl64.Vec3, fade float64) {
+	v := c.Relative(p)
+	r.buffer = append(r.buffer, [4]float32{v[0], v[1], v[2], float32(fade)})
+}
+
+// marks the vertices added since the last call as one line
+func (r *LineRenderer) end(first int) {
+	if len(r.buffer) > first {
+		r.firsts = append(r.firsts, int32(first))
+		r.counts = append(r.counts, int32(len(r.buffer)-first))
+	}
+}
+
+func (r *LineRenderer) flush(c *Camera, mode uint32, color [3]float32) {
+	if len(r.buffer) == 0 {
+		return
+	}
+
+	gl.UseProgram(r.program)
+	r.uniforms.Set(c)
+	gl.Uniform3f(r.colorU, color[0], color[1], color[2])
+
+	gl.BindVertexArray(r.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.vbo)
+	gl.BufferData(
+		gl.ARRAY_BUFFER,
+		int(unsafe.Sizeof(r.buffer[0]))*len(r.buffer),
+		unsafe.Pointer(&r.buffer[0]),
+		gl.STREAM_DRAW,
+	)
+	for i := range r.firsts {
+		gl.DrawArrays(mode, r.firsts[i], r.counts[i])
+	}
+	gl.BindVertexArray(0)
+}
+
+// Draws every non-nil trail as a line strip ending in the current position of its body,
+// fading out towards the oldest sample. The samples are coordinates in the frame fs.
+func (r *LineRenderer) DrawTrails(c *Camera, trails []*Trail, ps ParticleSystem, fs *FrameState) {
+	r.reset()
+	for i, t := range trails {
+		if t == nil || t.Len() == 0 {
+			continue
+		}
+		first := len(r.buffer)
+		n := t.Len() + 1
+		for j := 0; j < t.Len(); j++ {
+			r.vertex(c, fs.World(t.At(j)), float64(j+1)/float64(n))
+		}
+		r.vertex(c, ps[i].Position, 1)
+		r.end(first)
+	}
+	r.flush(c, gl.LINE_STRIP, trailColor)
+}
+
+// Draws the paths as dashed lines, every other segment is left out. The points are coordinates in the frame fs.
+func (r *LineRenderer) DrawPaths(c *Camera, paths [][]mgl64.Vec3, fs *FrameState) {
+	r.reset()
+	for _, p := range paths {
+		first := len(r.buffer)
+		for j := 0; j+1 < len(p); j += 2 {
+			r.vertex(c, fs.World(p[j]), 1)
+			r.vertex(c, fs.World(p[j+1]), 1)
+		}
+		r.end(first)
+	}
+	r.flush(c, gl.LINES, predictionColor)
+}
+
+// Draws a cross at every point, which keeps its size on the screen.
+func (r *LineRenderer) DrawMarkers(c *Camera, points []mgl64.Vec3) {
+	r.reset()
+	first := len(r.buffer)
+	for _, p := range points {
+		s := markerPixels * c.PixelSize(p.Sub(*c.position).Len())
+		for _, a := range []mgl64.Vec3{{s, 0, 0}, {0, s, 0}, {0, 0, s}} {
+			r.vertex(c, p.Sub(a), 1)
+			r.vertex(c, p.Add(a), 1)
+		}
+	}
+	r.end(first)
+	r.flush(c, gl.LINES, markerColor)
+}
+
+var lineVertexShaderSource = `
+#version 330 core
+
+uniform mat4 view;
+
+layout (location = 0) in vec4 vert; // position and fade
+
+out float fade;
+out float depthW;
+
+void main() {
+    gl_Position = view * vec4(vert.xyz, 1.0f);
+	fade = vert.w;
+	depthW = gl_Position.w;
+}
+` + "\x00"
+
+var lineFragmentShaderSource = `
+#version 330 core
+out vec4 outputColor;
+in float fade;
+in float depthW;
+
+uniform vec3 color;
+uniform float depthC;
+uniform float depthCoef;
+
+void main()
+{
+	outputColor = vec4(color, fade);
+	gl_FragDepth = depthCoef > 0 ? log2(depthC * depthW + 1) * depthCoef : gl_FragCoord.z;
+}
+` + "\x00"
@@ -2,10 +2,10 @@ package main
 
 import (
 	"fmt"
-	"github.com/luisgargitter/numerics"
 	_ "image/jpeg"
 	"log"
 	"math"
+	"os"
 	"runtime"
 	"strings"
 	"time"
@@ -18,10 +18,16 @@ import (
 const mouse_sensi = 0.0005
 const width, height = 1600, 1200
 
-const glCorrectionScale = 10e-9
+// gl units per meter, keeps the solar system within the precision of float32
+const glUnitsPerMeter = 1e-8
+
+// gl units per unit of length of the simulation, see useUnits
+var glCorrectionScale = glUnitsPerMeter
 
 const fpsTarget = 60.0
 
+const maxWarp = 1e5
+
 func init() {
 	// GLFW event handling must run on the main OS thread
 	runtime.LockOSThread()
@@ -51,7 +57,7 @@ func glfw_setup() *glfw.Window {
 	return window
 }
 
-func gl_setup() int32 {
+func gl_setup() (uint32, Uniforms) {
 	gl.Viewport(0, 0, width, height)
 
 	gl.Enable(gl.CULL_FACE)
@@ -63,6 +69,9 @@ func gl_setup() int32 {
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthFunc(gl.LESS)
 
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+
 	fmt.Println("Compiling Shaders...")
 	program, err := newProgram(vertexShaderSource, fragmentShaderSource)
 	if err != nil {
@@ -71,66 +80,133 @@ func gl_setup() int32 {
 	fmt.Println("Compilation Done.")
 	gl.UseProgram(program)
 
-	return gl.GetUniformLocation(program, gl.Str("view\x00"))
+	return program, getUniforms(program)
 }
 
 func main() {
 	fmt.Println("Initialization...")
 	window := glfw_setup()
 	defer glfw.Terminate()
-	viewU := gl_setup()
+	program, uniforms := gl_setup()
 
-	p := Pov{mgl64.Vec3{0, 0, 20e9}, mgl64.Vec3{0, 0, 1}, mgl64.Vec3{0, 1, 0}}
+	fmt.Println("Loading Planetary System...")
+	config, u := loadConfig("solar_system.toml")
+	useUnits(u)
+	constants := u.Constants()
+
+	p := Pov{mgl64.Vec3{0, 0, units.FromSI(20e9, dimLength)}, mgl64.Vec3{0, 0, 1}, mgl64.Vec3{0, 1, 0}}
 
 	var c Controls
 	c.Window = *window
 	c.P = p
 	c.Velocity = mgl64.Vec3{0, 0, 0}
-	c.Acceleration = 1000
+	c.Acceleration = units.FromSI(1000, dimSpeed)
 	c.Resistance = 1.0
 	c.PlanetIndex = 3
+	c.ShowTrails = true
+	c.ShowPaths = true
 	c.Setup()
 
 	sphere_vao := loadSphere(5, 1.0)
+	rock_vao := loadSphere(1, 1.0)
 
-	fmt.Println("Loading Planetary System...")
-	particles, radii, textures, names := constructSystem("solar_system.toml")
+	particles, bodies, layout := constructSystem(config, &constants)
 	fmt.Println("Planetary System Loaded.")
 
+	// transforms are set every frame
 	objects := make([]Object, len(particles))
 	for i := range particles {
-		pos := particles[i].Position.Mul(glCorrectionScale)
-		r := radii[i] * 10 * glCorrectionScale
-		t := mgl64.Translate3D(pos[0], pos[1], pos[2]).Mul4(mgl64.Scale3D(r, r, r)).Mul4(mgl64.HomogRotate3D(-math.Pi/2, mgl64.Vec3{1, 0, 0}))
-		objects[i] = Object{t, textures[i], sphere_vao}
+		vao := sphere_vao
+		if bodies[i].Small {
+			vao = rock_vao
+		}
+		objects[i] = Object{mgl64.Ident4(), bodies[i].Texture, vao, bodies[i].Emissive}
 	}
+	scale := config.Scale
+	prim := primaries(particles, bodies)
 
-	timeScale := 1000.0
+	// belt members come last and are skipped when cycling through the planets
+	planets := layout.Bodies.End
 
-	rk4w := numerics.RK4Workspace[ParticleSystem]{
-		Add: particleSystemAdd,
-		Mul: particleSystemMul,
-		D:   make(ParticleSystem, len(particles)),
-		K1:  make(ParticleSystem, len(particles)),
-		K2:  make(ParticleSystem, len(particles)),
-		K3:  make(ParticleSystem, len(particles)),
-		K4:  make(ParticleSystem, len(particles)),
-	}
+	timeScale := units.FromSI(1000, dimTime) // simulated time per second
+	warp := 1.0
+
+	rk4w := newRK4Workspace(len(particles))
+	warpw := newRK4Workspace(planets)
 
 	camera := Camera{
 		&c.P.Position, &c.P.Orientation, &c.P.Up,
 		math.Pi / 4.0, float64(width) / float64(height),
-		1e7,
-		1.0e12,
+		units.FromSI(1e7, dimLength),
+		units.FromSI(1.0e12, dimLength),
+		config.Camera.LogarithmicDepth,
+	}
+	if camera.logDepth {
+		// the logarithmic depth resolves everything from a meter up to beyond the solar system
+		camera.near = units.FromSI(1, dimLength)
+		camera.far = units.FromSI(1e13, dimLength)
+	}
+	scene := Scene{&camera, objects, ConstructInstanceVBO(), getLightingUniforms(program)}
+
+	trails := make([]*Trail, len(particles))
+	for i := range trails {
+		if bodies[i].Trail {
+			trails[i] = NewTrail(config.Trails.Length, config.Trails.Interval)
+		}
+	}
+	lineRenderer := NewLineRenderer()
+	simTime := 0.0
+
+	dynamics := NewDynamics(config, particles, bodies, layout, constants)
+	schedule, err := NewSchedule(config.Maneuvers, particles, simTime, bodies, prim, dynamics)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	frame, err := newFrame(config.Frame, bodies)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// belts are tracers and do not influence the planets, so they are left out of the prediction
+	planetDynamics := PlanetDynamics(config, particles, bodies, layout, constants)
+	predictor := NewPredictor(config.Prediction, schedule, planetDynamics, &frame)
+	planner := NewTransferPlanner(config.Transfer, config.Prediction.Step)
+
+	soi := NewSOITracker(particles, prim)
+	markers, err := lagrangePairs(config.Lagrange, bodies)
+	if err != nil {
+		log.Fatal(err)
 	}
-	scene := Scene{&camera, objects}
+
+	detector := NewDetector()
+	for _, ec := range config.Events {
+		e, err := newEvent(ec, bodies, prim)
+		if err != nil {
+			log.Fatal(err)
+		}
+		detector.Add(e)
+	}
+	for _, e := range dynamics.ReentryEvents(bodies) {
+		detector.Add(e)
+	}
+	eventFile, err := os.Create(eventLogPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer eventFile.Close()
+	eventLog := log.New(eventFile, "", 0)
+	detector.Subscribe(func(o Occurrence) {
+		eventLog.Println(o.String())
+	})
 
 	var cpuTime, gpuTime, deltaTime float64
 
 	info := Info{
 		&c.P.Position, &c.Velocity, &c.P.Orientation,
 		&cpuTime, &gpuTime, &deltaTime,
-		&names, &c.Locked, &c.PlanetIndex,
+		&bodies, &particles, &constants, &prim, soi, detector, &scale, &frame,
+		&c.Locked, &c.PlanetIndex, &warp,
 	}
 
 	i := 0
@@ -142,22 +218,86 @@ func main() {
 			info.Print()
 		}
 
+		// the camera keeps its place in the frame, or relative to the planet while locked
+		cameraFrame := frame
+		if c.Locked {
+			cameraFrame = Frame{FrameBody, c.PlanetIndex, -1}
+		}
+		before := cameraFrame.At(particles)
+
 		// static behaviour
-		numerics.RK4(&rk4w, dParticleSystem, deltaTime*timeScale, &particles, &particles)
+		dt := deltaTime * timeScale * warp
+		if dt > config.Prediction.Step {
+			// too coarse for the belts, which follow their orbits analytically instead
+			s := schedule.With(planetDynamics)
+			if err := warpStep(&s, &warpw, particles, planets, prim, simTime, dt, config.Prediction.Step, detector); err != nil {
+				log.Println("fast forward failed:", err)
+			}
+		} else {
+			schedule.Step(&rk4w, &particles, simTime, dt, detector)
+		}
+		simTime += dt
+		after := cameraFrame.At(particles)
+		c.P.Carry(&before, &after)
+		fs := frame.At(particles)
+		for i, t := range trails {
+			if t != nil {
+				t.Record(simTime, fs.Position(particles[i].Position))
+			}
+		}
+		predictor.Update(particles[:planets], simTime)
+		for _, e := range soi.Update(particles, simTime) {
+			detector.Emit(e.Occurrence(particles, bodies))
+		}
 
-		c.Handle(particles, deltaTime*timeScale)
+		c.Handle(particles[:planets], deltaTime*timeScale)
+		if c.Export {
+			if err := exportFile(exportPath, particles, bodies, prim, &fs, &constants); err != nil {
+				log.Println("export failed:", err)
+			}
+		}
+		if c.PlanTransfer {
+			planner.Start(schedule.With(planetDynamics), particles[:planets], bodies, prim, simTime)
+		}
+		planner.Update(schedule, predictor, particles[:planets], bodies, prim, simTime)
+		if c.CycleFrame {
+			// the trails and paths were recorded in the old frame
+			frame.Next()
+			for _, t := range trails {
+				if t != nil {
+					t.Clear()
+				}
+			}
+			predictor.Invalidate()
+		}
+		if c.Warp != 0 {
+			warp = mgl64.Clamp(warp*math.Pow(10, float64(c.Warp)), 1, maxWarp)
+		}
+		if c.CycleScale {
+			scale.Mode = (scale.Mode + 1) % scaleModes
+		}
 
 		gl.ClearColor(0, 0, 0, 1)
 		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 		for i := range scene.objects {
-			pos := particles[i].Position.Mul(glCorrectionScale)
-			r := radii[i] * 10 * glCorrectionScale
-			scene.objects[i].Transform = mgl64.Translate3D(pos[0], pos[1], pos[2]).Mul4(mgl64.Scale3D(r, r, r).Mul4(mgl64.HomogRotate3D(-math.Pi/2, mgl64.Vec3{1, 0, 0})))
+			s := &scale
+			if bodies[i].Scale != nil {
+				s = bodies[i].Scale
+			}
+			scene.objects[i].Transform = bodyTransform(&particles[i], bodies[i].Radius, s, &camera)
 		}
 
 		cpuTime = glfw.GetTime() - t
 
-		scene.Draw(viewU)
+		gl.UseProgram(program)
+		scene.Draw(uniforms)
+		if c.ShowTrails {
+			lineRenderer.DrawTrails(&camera, trails, particles, &fs)
+		}
+		if c.ShowPaths {
+			lineRenderer.DrawPaths(&camera, predictor.Paths(simTime), &fs)
+		}
+		lineRenderer.DrawMarkers(&camera, lagrangePositions(particles, markers))
 		window.SwapBuffers()
 		gpuTime = glfw.GetTime() - (t + cpuTime)
 
@@ -243,14 +383,23 @@ void main()
 
 uniform mat4 view;
 
-in vec3 vert;
-in vec2 uv;
+layout (location = 0) in vec3 vert;
+layout (location = 1) in vec2 uv;
+layout (location = 2) in vec3 normal;
+layout (location = 3) in mat4 model;
 
 out vec2 texcoord;
+out vec3 position;
+out vec3 worldNormal;
+out float depthW;
 
 void main() {
-    gl_Position = mat4(view) * vec4(vert, 1.0f);
+	vec4 p = model * vec4(vert, 1.0f);
+    gl_Position = view * p;
 	texcoord = uv;
+	position = p.xyz;
+	worldNormal = mat3(model) * normal;
+	depthW = gl_Position.w;
 }
 ` + "\x00"
 
@@ -258,13 +407,32 @@ var fragmentShaderSource = `
 #version 330 core
 out vec4 outputColor;
 in vec2 texcoord;
+in vec3 position;
+in vec3 worldNormal;
+in float depthW;
 
 uniform sampler2D tex;
+uniform float depthC;
+uniform float depthCoef;
+
+const float ambient = 0.05;
+uniform vec3 lights[4]; // positions of the emissive bodies
+uniform int lightCount;
+uniform int emissive;
 
 void main()
 {
 	//outputColor = vec4(vec3(1/gl_FragCoord.z), 1.0);
 	outputColor = texture(tex, texcoord);
+	if (emissive == 0) {
+		vec3 n = normalize(worldNormal);
+		float light = ambient;
+		for (int i = 0; i < lightCount; i++) {
+			light += max(dot(n, normalize(lights[i] - position)), 0.0);
+		}
+		outputColor.rgb *= min(light, 1.0);
+	}
+	gl_FragDepth = depthCoef > 0 ? log2(depthC * depthW + 1) * depthCoef : gl_FragCoord.z;
     //outputColor = vec4(texcoord[0], texcoord[1], 0.0f, 1.0f);
 }
 ` + "\x00"
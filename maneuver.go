@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl64"
+	"github.com/luisgargitter/numerics"
+)
+
+// Maneuver is a burn of a body, either impulsive (DeltaV) or with finite thrust over a duration.
+// Vectors are given in the local frame of the orbit around the primary: prograde, normal and radial.
+type Maneuver struct {
+	Body      string
+	Primary   string    // defaults to the primary of the body
+	Time      float64   // simulated time of the start of the burn
+	DeltaV    []float64 `toml:"delta_v"` // impulsive burn
+	Thrust    float64   // finite burn in newton
+	Duration  float64
+	Direction []float64 // of the thrust
+	Isp       float64   // specific impulse in seconds, no mass is spent if zero
+	body      int
+	primary   int
+	dry       float64 // mass of the body without propellant
+}
+
+func (m *Maneuver) End() float64 {
+	return m.Time + m.Duration
+}
+
+func (m *Maneuver) Impulsive() bool {
+	return m.Thrust == 0
+}
+
+// Mass of the body after the burn, which starts with the given mass.
+func (m *Maneuver) burn(mass, g0 float64) float64 {
+	switch {
+	case m.Isp == 0:
+		return mass
+	case m.Impulsive():
+		return mass * math.Exp(-mgl64.Vec3{m.DeltaV[0], m.DeltaV[1], m.DeltaV[2]}.Len()/(m.Isp*g0))
+	}
+	return mass - m.Thrust*m.Duration/(m.Isp*g0)
+}
+
+// Resolves the names of the bodies of the maneuver against the system.
+func (m *Maneuver) resolve(bodies []Body, prim []int) error {
+	m.body = -1
+	m.primary = -1
+	for i, b := range bodies {
+		if b.Name == m.Body {
+			m.body = i
+		}
+		if m.Primary != "" && b.Name == m.Primary {
+			m.primary = i
+		}
+	}
+	if m.body == -1 {
+		return fmt.Errorf("maneuver of unknown body %q", m.Body)
+	}
+	if m.Primary == "" {
+		m.primary = prim[m.body]
+	}
+	m.dry = bodies[m.body].DryMass
+	if m.primary == -1 {
+		return fmt.Errorf("maneuver of %s has no primary", m.Body)
+	}
+	if (m.Impulsive() && len(m.DeltaV) != 3) || (!m.Impulsive() && len(m.Direction) != 3) {
+		return fmt.Errorf("maneuver of %s needs three components", m.Body)
+	}
+	return nil
+}
+
+// Prograde, normal and radial unit vectors of the orbit of p around a.
+func localFrame(p, a *Particle) (mgl64.Vec3, mgl64.Vec3, mgl64.Vec3) {
+	r := p.Position.Sub(a.Position)
+	v := p.Velocity.Sub(a.Velocity)
+	prograde := v.Normalize()
+	normal := r.Cross(v).Normalize()
+	radial := prograde.Cross(normal)
+	return prograde, normal, radial
+}
+
+// Converts a vector given in the local frame of the orbit of p around a into the world frame.
+func fromLocal(l []float64, p, a *Particle) mgl64.Vec3 {
+	pro, nor, rad := localFrame(p, a)
+	return pro.Mul(l[0]).Add(nor.Mul(l[1])).Add(rad.Mul(l[2]))
+}
+
+// Converts a world vector into the local frame of the orbit of p around a, the inverse of fromLocal.
+func toLocal(w mgl64.Vec3, p, a *Particle) []float64 {
+	pro, nor, rad := localFrame(p, a)
+	return []float64{w.Dot(pro), w.Dot(nor), w.Dot(rad)}
+}
+
+func (m *Maneuver) apply(ps ParticleSystem, k *Constants) {
+	p := &ps[m.body]
+	dv := fromLocal(m.DeltaV, p, &ps[m.primary])
+	p.Velocity = p.Velocity.Add(dv)
+	if m.Isp > 0 {
+		// tsiolkovsky
+		p.Mass *= math.Exp(-dv.Len() / (m.Isp * k.G0))
+	}
+}
+
+// Schedule applies maneuvers by simulated time while the system is integrated.
+// Step does not modify the schedule, so it may be shared with the predictor.
+type Schedule struct {
+	maneuvers []Maneuver // sorted by time
+	dynamics  *Dynamics
+}
+
+// The maneuvers scheduled so far, integrated with the dynamics d of a part of the system,
+// e.g. the planets for the predictor. Maneuvers added later are not included.
+func (s *Schedule) With(d *Dynamics) Schedule {
+	return Schedule{s.maneuvers, d}
+}
+
+func NewSchedule(ms []Maneuver, ps ParticleSystem, t float64, bodies []Body, prim []int, d *Dynamics) (*Schedule, error) {
+	s := &Schedule{dynamics: d}
+	for _, m := range ms {
+		if err := s.Add(m, ps, t, bodies, prim); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Inserts a maneuver. The maneuvers are copied, so schedules handed out earlier stay unchanged.
+// The burns of the body that have not ended at the time t of the state ps have to leave it above
+// its dry mass, counted in full even if they have already started.
+func (s *Schedule) Add(m Maneuver, ps ParticleSystem, t float64, bodies []Body, prim []int) error {
+	if err := m.resolve(bodies, prim); err != nil {
+		return err
+	}
+	ms := append(append([]Maneuver(nil), s.maneuvers...), m)
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].Time < ms[j].Time })
+	mass := ps[m.body].Mass
+	for i := range ms {
+		if ms[i].body == m.body && ms[i].End() >= t {
+			mass = ms[i].burn(mass, s.dynamics.Constants.G0)
+		}
+	}
+	if mass <= m.dry {
+		return fmt.Errorf("maneuvers of %s need more propellant than it has", m.Body)
+	}
+	s.maneuvers = ms
+	return nil
+}
+
+// derivative with the thrust of the given burns
+func (s *Schedule) derivative(burns []*Maneuver) func(y *ParticleSystem, dy *ParticleSystem) {
+	if len(burns) == 0 {
+		return s.dynamics.Derivative
+	}
+	g0 := s.dynamics.Constants.G0
+	return func(y *ParticleSystem, dy *ParticleSystem) {
+		s.dynamics.Derivative(y, dy)
+		for _, m := range burns {
+			p := &(*y)[m.body]
+			if m.Isp > 0 && p.Mass <= m.dry {
+				continue // out of propellant
+			}
+			a := fromLocal(m.Direction, p, &(*y)[m.primary]).Normalize().Mul(m.Thrust / p.Mass)
+			(*dy)[m.body].Velocity = (*dy)[m.body].Velocity.Add(a)
+			if m.Isp > 0 {
+				(*dy)[m.body].Mass -= m.Thrust / (m.Isp * g0)
+			}
+		}
+	}
+}
+
+// usable reports whether the maneuver acts on bodies within ps, the predictor only integrates a prefix of the system.
+func (m *Maneuver) usable(ps ParticleSystem) bool {
+	return m.body < len(ps) && m.primary < len(ps)
+}
+
+// Observer follows the integration after every step, see Detector.
+type Observer interface {
+	Observe(ps ParticleSystem, t float64)
+	Reset() // the state jumped, so it does not continue the previous one
+}
+
+// Advances ps from time t by h. The step is split wherever a burn starts or ends,
+// impulsive maneuvers are applied at exactly their time. The optional observer sees every part of the step.
+func (s *Schedule) Step(w *numerics.RK4Workspace[ParticleSystem], ps *ParticleSystem, t, h float64, o Observer) {
+	var edges []float64
+	for i := range s.maneuvers {
+		m := &s.maneuvers[i]
+		if !m.usable(*ps) {
+			continue
+		}
+		for _, e := range []float64{m.Time, m.End()} {
+			if e >= t && e < t+h && !containsFloat(edges, e) {
+				edges = append(edges, e)
+			}
+		}
+	}
+	sort.Float64s(edges)
+
+	cur := t
+	for _, e := range edges {
+		s.integrate(w, ps, cur, e)
+		cur = e
+		jumped := false
+		for i := range s.maneuvers {
+			m := &s.maneuvers[i]
+			if m.Impulsive() && m.Time == e && m.usable(*ps) {
+				m.apply(*ps, &s.dynamics.Constants)
+				jumped = true
+			}
+		}
+		if o != nil {
+			o.Observe(*ps, e)
+			if jumped {
+				o.Reset()
+				o.Observe(*ps, e)
+			}
+		}
+	}
+	s.integrate(w, ps, cur, t+h)
+	if o != nil {
+		o.Observe(*ps, t+h)
+	}
+}
+
+// integrates from t0 to t1 with the burns active in between
+func (s *Schedule) integrate(w *numerics.RK4Workspace[ParticleSystem], ps *ParticleSystem, t0, t1 float64) {
+	if t1 <= t0 {
+		return
+	}
+	var burns []*Maneuver
+	mid := (t0 + t1) / 2
+	for i := range s.maneuvers {
+		m := &s.maneuvers[i]
+		if !m.Impulsive() && m.usable(*ps) && m.Time <= mid && mid < m.End() {
+			burns = append(burns, m)
+		}
+	}
+	stepParticleSystem(w, s.derivative(burns), t1-t0, ps)
+	// the last substep may burn a little beyond the propellant
+	for _, m := range burns {
+		if m.Isp > 0 && (*ps)[m.body].Mass < m.dry {
+			(*ps)[m.body].Mass = m.dry
+		}
+	}
+}
+
+func containsFloat(s []float64, f float64) bool {
+	for _, e := range s {
+		if e == f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// The burns of a craft together have to leave it above its dry mass.
+func TestScheduleRejectsBurnsBeyondPropellant(t *testing.T) {
+	prim := []int{-1, 0}
+	finite := Maneuver{Body: "craft", Time: 100, Thrust: 1000, Duration: 1000, Direction: []float64{1, 0, 0}, Isp: 300} // spends 340 kg
+	impulsive := Maneuver{Body: "craft", Time: 10, DeltaV: []float64{0, 500, 0}, Isp: 300}                              // spends 16 % of the mass
+	later := finite
+	later.Time = 5000
+	cases := []struct {
+		name      string
+		mass, dry float64
+		t         float64
+		ms        []Maneuver
+		ok        bool
+	}{
+		{"one burn", 400, 0, 0, []Maneuver{finite}, true},
+		{"one burn beyond the mass", 300, 0, 0, []Maneuver{finite}, false},
+		{"one burn beyond the dry mass", 400, 100, 0, []Maneuver{finite}, false},
+		{"two burns", 800, 100, 0, []Maneuver{finite, later}, true},
+		{"two burns beyond the mass", 400, 0, 0, []Maneuver{finite, later}, false},
+		{"impulsive burn first", 400, 50, 0, []Maneuver{finite, impulsive}, false},
+		{"impulsive burn already spent", 400, 50, 50, []Maneuver{finite, impulsive}, true},
+		{"finite burn already spent", 400, 0, 2000, []Maneuver{finite, later}, true},
+	}
+	for _, c := range cases {
+		bodies := []Body{{Name: "sun", Parent: -1}, {Name: "craft", Parent: 0, DryMass: c.dry}}
+		ps := ParticleSystem{{Mass: solarMass}, {Mass: c.mass}}
+		s, err := NewSchedule(c.ms[:len(c.ms)-1], ps, c.t, bodies, prim, NewDynamics(Config{}, ps, bodies, Layout{}, siConstants))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if err := s.Add(c.ms[len(c.ms)-1], ps, c.t, bodies, prim); (err == nil) != c.ok {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+}
+
+// A burn longer than the propellant lasts stops at the dry mass, with the delta-v of the rocket equation.
+func TestBurnStopsAtDryMass(t *testing.T) {
+	const m0, dry, h = 110.0, 100.0, 1.0
+	ps := ParticleSystem{
+		{Mass: solarMass, Orientation: mgl64.QuatIdent()},
+		{Position: mgl64.Vec3{1.5e11, 0, 0}, Velocity: mgl64.Vec3{0, 0, 3e4}, Mass: m0, Tracer: true, Orientation: mgl64.QuatIdent()},
+	}
+	m := Maneuver{Thrust: 1000, Duration: 1000, Direction: []float64{1, 0, 0}, Isp: 300, body: 1, primary: 0, dry: dry}
+	burning := &Schedule{[]Maneuver{m}, NewDynamics(Config{}, ps, nil, Layout{}, siConstants)}
+	coasting := &Schedule{nil, burning.dynamics}
+
+	burnt := append(ParticleSystem(nil), ps...)
+	w := newRK4Workspace(len(ps))
+	for i := 0; i < int(m.Duration/h); i++ {
+		burning.Step(&w, &burnt, float64(i)*h, h, nil)
+		coasting.Step(&w, &ps, float64(i)*h, h, nil)
+	}
+
+	if burnt[1].Mass != dry {
+		t.Errorf("mass %v after the burn, want %v", burnt[1].Mass, dry)
+	}
+	want := m.Isp * G0 * math.Log(m0/dry)
+	if dv := burnt[1].Velocity.Sub(ps[1].Velocity).Len(); math.Abs(dv-want) > 0.01*want {
+		t.Errorf("delta-v %v m/s, want %v m/s", dv, want)
+	}
+}
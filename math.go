@@ -19,6 +19,14 @@ func Arrayf64Tof32(s []float64, d *[]float32) {
 	}
 }
 
+func Mat4f64Tof32(m mgl64.Mat4) mgl32.Mat4 {
+	var r mgl32.Mat4
+	for i := range m {
+		r[i] = float32(m[i])
+	}
+	return r
+}
+
 func triangleNumber(n int) int {
 	return n * (n - 1) / 2
 }
@@ -48,9 +48,11 @@ func (m *Mesh) Enhance() {
 
 func (m *Mesh) PuffUp(radius float64) {
 	m.UVcoords = make([]mgl64.Vec2, len(m.Vertices))
+	m.Normals = make([]mgl64.Vec3, len(m.Vertices))
 	for i, p := range m.Vertices {
 		_, theta, phi := mgl64.CartesianToSpherical(p)
 		m.Vertices[i] = mgl64.SphericalToCartesian(radius, theta, phi)
+		m.Normals[i] = mgl64.SphericalToCartesian(1, theta, phi)
 		m.UVcoords[i] = mgl64.Vec2{(phi + math.Pi), 2 * theta}.Mul(1 / (2 * math.Pi))
 	}
 }
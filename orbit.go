@@ -0,0 +1,164 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// tolerance below which an orbit is treated as circular or equatorial
+const orbitEps = 1e-10
+
+// The simulation uses y as up and the configured orbits run clockwise when seen from +y.
+// The ecliptic frame therefore is spanned by x and z with its pole pointing along -y.
+var (
+	eclipticX = mgl64.Vec3{1, 0, 0}
+	eclipticY = mgl64.Vec3{0, 0, 1}
+	eclipticZ = mgl64.Vec3{0, -1, 0}
+)
+
+func toEcliptic(v mgl64.Vec3) mgl64.Vec3 {
+	return mgl64.Vec3{v.Dot(eclipticX), v.Dot(eclipticY), v.Dot(eclipticZ)}
+}
+
+func fromEcliptic(v mgl64.Vec3) mgl64.Vec3 {
+	return eclipticX.Mul(v[0]).Add(eclipticY.Mul(v[1])).Add(eclipticZ.Mul(v[2]))
+}
+
+// OrbitalElements are the osculating keplerian elements of a body relative to its primary.
+// Lengths are in meters, angles in radians and the period in seconds.
+type OrbitalElements struct {
+	SemiMajorAxis float64 // negative for hyperbolic, infinite for parabolic orbits
+	Eccentricity  float64
+	Inclination   float64
+	Node          float64 // longitude of the ascending node
+	Periapsis     float64 // argument of periapsis
+	TrueAnomaly   float64
+	Period        float64 // infinite for unbound orbits
+}
+
+// angle between a and b in [0, pi]
+func angleBetween(a, b mgl64.Vec3) float64 {
+	return math.Atan2(a.Cross(b).Len(), a.Dot(b))
+}
+
+// maps an angle into [0, 2pi)
+func wrapAngle(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	return a
+}
+
+// Computes the elements of an orbit with relative position r, relative velocity v and gravitational parameter mu.
+// For equatorial orbits the node is set to zero and the periapsis is measured from the x-axis,
+// for circular orbits the periapsis is set to zero and the anomaly is measured from the node.
+func ElementsFromState(r, v mgl64.Vec3, mu float64) OrbitalElements {
+	r = toEcliptic(r)
+	v = toEcliptic(v)
+	var o OrbitalElements
+
+	h := r.Cross(v)
+	n := mgl64.Vec3{-h[1], h[0], 0}
+	rl := r.Len()
+	ev := r.Mul(v.LenSqr() - mu/rl).Sub(v.Mul(r.Dot(v))).Mul(1 / mu)
+	energy := v.LenSqr()/2 - mu/rl
+
+	o.Eccentricity = ev.Len()
+	o.Inclination = angleBetween(mgl64.Vec3{0, 0, 1}, h)
+	if math.Abs(o.Eccentricity-1) < orbitEps {
+		o.SemiMajorAxis = math.Inf(1)
+	} else {
+		o.SemiMajorAxis = -mu / (2 * energy)
+	}
+	if o.Eccentricity < 1 {
+		o.Period = 2 * math.Pi * math.Sqrt(math.Pow(o.SemiMajorAxis, 3)/mu)
+	} else {
+		o.Period = math.Inf(1)
+	}
+
+	equatorial := n.Len() < orbitEps*h.Len()
+	circular := o.Eccentricity < orbitEps
+	retrograde := h[2] < 0
+
+	if !equatorial {
+		o.Node = wrapAngle(math.Atan2(n[1], n[0]))
+	} else {
+		// the x-axis takes the role of the line of nodes
+		n = mgl64.Vec3{1, 0, 0}
+	}
+
+	switch {
+	case circular:
+		o.Periapsis = 0
+		o.TrueAnomaly = angleBetween(n, r)
+		if h.Dot(n.Cross(r)) < 0 {
+			o.TrueAnomaly = 2*math.Pi - o.TrueAnomaly
+		}
+	case equatorial:
+		o.Periapsis = wrapAngle(math.Atan2(ev[1], ev[0]))
+		if retrograde {
+			o.Periapsis = wrapAngle(-o.Periapsis)
+		}
+	default:
+		o.Periapsis = angleBetween(n, ev)
+		if ev[2] < 0 {
+			o.Periapsis = 2*math.Pi - o.Periapsis
+		}
+	}
+	if !circular {
+		o.TrueAnomaly = angleBetween(ev, r)
+		if r.Dot(v) < 0 {
+			o.TrueAnomaly = 2*math.Pi - o.TrueAnomaly
+		}
+	}
+
+	return o
+}
+
+// Computes the osculating elements of p relative to the primary a.
+func (p *Particle) Elements(a *Particle, k *Constants) OrbitalElements {
+	return ElementsFromState(p.Position.Sub(a.Position), p.Velocity.Sub(a.Velocity), p.Mu(a, k))
+}
+
+// Returns the index of the primary of each body: the configured parent if there is one,
+// the most massive body otherwise. The most massive body itself has no primary (-1).
+func primaries(ps ParticleSystem, bodies []Body) []int {
+	heaviest := -1
+	for i := range ps {
+		if heaviest == -1 || ps[i].Mass > ps[heaviest].Mass {
+			heaviest = i
+		}
+	}
+
+	r := make([]int, len(ps))
+	for i := range ps {
+		switch {
+		case bodies[i].Parent >= 0:
+			r[i] = bodies[i].Parent
+		case i == heaviest:
+			r[i] = -1
+		default:
+			r[i] = heaviest
+		}
+	}
+	return r
+}
+
+// Inverse of ElementsFromState for all but parabolic orbits: the relative position and velocity described by o.
+func StateFromElements(o OrbitalElements, mu float64) (mgl64.Vec3, mgl64.Vec3) {
+	e := o.Eccentricity
+	p := o.SemiMajorAxis * (1 - e*e)
+	sn, cn := math.Sincos(o.TrueAnomaly)
+
+	// perifocal frame, x towards the periapsis
+	r := mgl64.Vec3{cn, sn, 0}.Mul(p / (1 + e*cn))
+	v := mgl64.Vec3{-sn, e + cn, 0}.Mul(math.Sqrt(mu / p))
+
+	q := mgl64.QuatRotate(o.Node, mgl64.Vec3{0, 0, 1}).
+		Mul(mgl64.QuatRotate(o.Inclination, mgl64.Vec3{1, 0, 0})).
+		Mul(mgl64.QuatRotate(o.Periapsis, mgl64.Vec3{0, 0, 1}))
+
+	return fromEcliptic(q.Rotate(r)), fromEcliptic(q.Rotate(v))
+}
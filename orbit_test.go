@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// difference of two angles in [-pi, pi)
+func angleDiff(a, b float64) float64 {
+	return wrapAngle(a-b+math.Pi) - math.Pi
+}
+
+func TestElementsRoundTrip(t *testing.T) {
+	const mu = 3.986004418e14
+	cases := []struct {
+		name string
+		o    OrbitalElements
+	}{
+		{"circular", OrbitalElements{7e6, 0, 0.5, 1.2, 0, 2.0, 0}},
+		{"elliptic", OrbitalElements{2.4e7, 0.7, 0.9, 4.0, 1.1, 0.3, 0}},
+		{"hyperbolic", OrbitalElements{-1e7, 1.8, 0.4, 2.5, 5.0, 1.0, 0}},
+		{"equatorial prograde", OrbitalElements{1e7, 0.2, 0, 0, 2.2, 3.5, 0}},
+		{"equatorial retrograde", OrbitalElements{1e7, 0.2, math.Pi, 0, 2.2, 3.5, 0}},
+		{"polar", OrbitalElements{9e6, 0.1, math.Pi / 2, 0.7, 0.4, 5.5, 0}},
+	}
+	for _, c := range cases {
+		r, v := StateFromElements(c.o, mu)
+		o := ElementsFromState(r, v, mu)
+		if math.Abs(o.SemiMajorAxis-c.o.SemiMajorAxis) > 1e-9*math.Abs(c.o.SemiMajorAxis) {
+			t.Errorf("%s: semi-major axis %v, want %v", c.name, o.SemiMajorAxis, c.o.SemiMajorAxis)
+		}
+		if math.Abs(o.Eccentricity-c.o.Eccentricity) > 1e-9 {
+			t.Errorf("%s: eccentricity %v, want %v", c.name, o.Eccentricity, c.o.Eccentricity)
+		}
+		for _, a := range []struct {
+			name      string
+			got, want float64
+		}{
+			{"inclination", o.Inclination, c.o.Inclination},
+			{"node", o.Node, c.o.Node},
+			{"periapsis", o.Periapsis, c.o.Periapsis},
+			{"true anomaly", o.TrueAnomaly, c.o.TrueAnomaly},
+		} {
+			if math.Abs(angleDiff(a.got, a.want)) > 1e-7 {
+				t.Errorf("%s: %s %v, want %v", c.name, a.name, a.got, a.want)
+			}
+		}
+		if c.o.Eccentricity < 1 {
+			if want := 2 * math.Pi * math.Sqrt(math.Pow(c.o.SemiMajorAxis, 3)/mu); math.Abs(o.Period-want) > 1e-9*want {
+				t.Errorf("%s: period %v, want %v", c.name, o.Period, want)
+			}
+		} else if !math.IsInf(o.Period, 1) {
+			t.Errorf("%s: period %v of an unbound orbit", c.name, o.Period)
+		}
+	}
+}
+
+// The configured orbits run clockwise seen from +y, which is prograde in the ecliptic frame.
+func TestElementsOfConfiguredOrbit(t *testing.T) {
+	const mu = 1.32712440018e20
+	r, v := 1.496e11, 29.78e3
+	o := ElementsFromState(mgl64.Vec3{r, 0, 0}, mgl64.Vec3{0, 0, v}, mu)
+	if o.Inclination > 1e-12 {
+		t.Errorf("inclination %v, want 0", o.Inclination)
+	}
+	if o.Eccentricity > 0.01 {
+		t.Errorf("eccentricity %v of a nearly circular orbit", o.Eccentricity)
+	}
+}
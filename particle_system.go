@@ -2,16 +2,32 @@ package main
 
 import (
 	"github.com/go-gl/mathgl/mgl64"
+	"github.com/luisgargitter/numerics"
 )
 
 type ParticleSystem []Particle
 
+func newRK4Workspace(n int) numerics.RK4Workspace[ParticleSystem] {
+	return numerics.RK4Workspace[ParticleSystem]{
+		Add: particleSystemAdd,
+		Mul: particleSystemMul,
+		D:   make(ParticleSystem, n),
+		K1:  make(ParticleSystem, n),
+		K2:  make(ParticleSystem, n),
+		K3:  make(ParticleSystem, n),
+		K4:  make(ParticleSystem, n),
+	}
+}
+
 func particleSystemAdd(d *ParticleSystem, a *ParticleSystem, b *ParticleSystem) *ParticleSystem {
 	for i := range *a {
 		(*d)[i].Position = (*a)[i].Position.Add((*b)[i].Position)
 		(*d)[i].Velocity = (*a)[i].Velocity.Add((*b)[i].Velocity)
 		(*d)[i].Mass = (*a)[i].Mass + (*b)[i].Mass
 		(*d)[i].Charge = (*a)[i].Charge + (*b)[i].Charge
+		(*d)[i].Tracer = (*a)[i].Tracer
+		(*d)[i].Orientation = (*a)[i].Orientation.Add((*b)[i].Orientation)
+		(*d)[i].AngularVelocity = (*a)[i].AngularVelocity.Add((*b)[i].AngularVelocity)
 	}
 	return d
 }
@@ -22,29 +38,66 @@ func particleSystemMul(d *ParticleSystem, a *ParticleSystem, c float64) *Particl
 		(*d)[i].Velocity = (*a)[i].Velocity.Mul(c)
 		(*d)[i].Mass = (*a)[i].Mass * c
 		(*d)[i].Charge = (*a)[i].Charge * c
+		(*d)[i].Tracer = (*a)[i].Tracer
+		(*d)[i].Orientation = (*a)[i].Orientation.Scale(c)
+		(*d)[i].AngularVelocity = (*a)[i].AngularVelocity.Mul(c)
 	}
 	return d
 }
 
-func dParticleSystem(y *ParticleSystem, dy *ParticleSystem) {
+// Advances the system by h and pulls the orientations, which drift off the unit sphere, back onto it.
+func stepParticleSystem(w *numerics.RK4Workspace[ParticleSystem], f func(y *ParticleSystem, dy *ParticleSystem), h float64, ps *ParticleSystem) {
+	numerics.RK4(w, f, h, ps, ps)
+	for i := range *ps {
+		(*ps)[i].Orientation = (*ps)[i].Orientation.Normalize()
+	}
+}
+
+// indices of all particles which exert gravity
+func massiveIndices(ps ParticleSystem) []int {
+	var r []int
+	for i := range ps {
+		if !ps[i].Tracer {
+			r = append(r, i)
+		}
+	}
+	return r
+}
+
+// Only pairs of massive particles interact with each other, tracers are pulled by the massive particles alone.
+// This costs O(n_massive * n) instead of O(n^2).
+func dParticleSystem(k *Constants, y *ParticleSystem, dy *ParticleSystem) {
 	for i := range *dy {
 		(*dy)[i].Velocity = mgl64.Vec3{0, 0, 0}
 	}
 
-	for i := range *y {
+	massive := massiveIndices(*y)
+	for n, i := range massive {
 		p1 := &(*y)[i]
-		for j := i + 1; j < len(*y); j++ {
+		for _, j := range massive[n+1:] {
 			p2 := &(*y)[j]
 
-			f := p1.GravitationalForceV(p2)
+			f := p1.GravitationalForceV(p2, k)
 			fp1 := f.Mul(1.0 / p1.Mass)
 			fp2 := f.Mul(-1.0 / p2.Mass)
 
 			(*dy)[i].Velocity = (*dy)[i].Velocity.Add(fp1)
 			(*dy)[j].Velocity = (*dy)[j].Velocity.Add(fp2)
 		}
+	}
+
+	for i := range *y {
+		p := &(*y)[i]
+		if p.Tracer {
+			for _, j := range massive {
+				(*dy)[i].Velocity = (*dy)[i].Velocity.Add(p.GravitationalAccelerationV(&(*y)[j], k))
+			}
+		}
 		// change in Position
-		(*dy)[i].Position = (*y)[i].Velocity
+		(*dy)[i].Position = p.Velocity
+		// attitude, the torques are collected in the angular velocity, see Dynamics.attitude
+		(*dy)[i].Orientation = p.Spin()
+		(*dy)[i].AngularVelocity = mgl64.Vec3{0, 0, 0}
 		// ensure mass and charge do not change
 		(*dy)[i].Mass = 0
 		(*dy)[i].Charge = 0
@@ -5,16 +5,39 @@ import (
 	"math"
 )
 
+// in SI units
 const (
 	G    = 6.6743015e-11
 	Eps0 = 8.8541878128e-12
+	G0   = 9.80665 // standard gravity, as used by specific impulses
+
+	SpeedOfLight = 299792458.0
 )
 
+// Constants are the physical constants in the units the system is integrated in, see Units.Constants.
+type Constants struct {
+	G            float64
+	Eps0         float64
+	G0           float64
+	SpeedOfLight float64
+}
+
+var siConstants = Constants{G, Eps0, G0, SpeedOfLight}
+
 type Particle struct {
-	Position mgl64.Vec3
-	Velocity mgl64.Vec3
-	Mass     float64
-	Charge   float64
+	Position        mgl64.Vec3
+	Velocity        mgl64.Vec3
+	Mass            float64
+	Charge          float64
+	Tracer          bool       // feels the gravity of non-tracers but exerts none itself
+	Orientation     mgl64.Quat // rotation from the body frame, whose north pole is +y, into the world frame
+	AngularVelocity mgl64.Vec3 // in the world frame
+}
+
+// Zonal holds the zonal harmonic coefficients of an oblate body, which is symmetric about its rotation axis.
+type Zonal struct {
+	J      [5]float64 // by degree, J[2] to J[4] are used
+	Radius float64    // reference radius of the coefficients
 }
 
 type Link struct {
@@ -23,11 +46,11 @@ type Link struct {
 	damperConstant float64
 }
 
-func (p *Particle) GravitationalForceV(a *Particle) mgl64.Vec3 {
+func (p *Particle) GravitationalForceV(a *Particle, k *Constants) mgl64.Vec3 {
 	deltaPosition := a.Position.Sub(p.Position)
 	distanceSquared := deltaPosition.LenSqr()
-	Fg := G * p.Mass * a.Mass / distanceSquared
-	Fc := p.Charge * a.Charge / (4 * math.Pi * Eps0 * distanceSquared)
+	Fg := k.G * p.Mass * a.Mass / distanceSquared
+	Fc := p.Charge * a.Charge / (4 * math.Pi * k.Eps0 * distanceSquared)
 	F := Fg - Fc
 
 	direction := deltaPosition.Normalize()
@@ -35,6 +58,73 @@ func (p *Particle) GravitationalForceV(a *Particle) mgl64.Vec3 {
 	return direction.Mul(F)
 }
 
+// Change of the orientation caused by the angular velocity.
+func (p *Particle) Spin() mgl64.Quat {
+	return mgl64.Quat{W: 0, V: p.AngularVelocity}.Mul(p.Orientation).Scale(0.5)
+}
+
+// The inertia tensor given in the body frame, turned into the world frame.
+func (p *Particle) WorldInertia(inertia mgl64.Mat3) mgl64.Mat3 {
+	r := p.Orientation.Normalize().Mat4().Mat3()
+	return r.Mul3(inertia).Mul3(r.Transpose())
+}
+
+// Angular acceleration from euler's equations, L = I w and dL/dt = torque, evaluated in the world frame.
+func (p *Particle) AngularAcceleration(inertia mgl64.Mat3, torque mgl64.Vec3) mgl64.Vec3 {
+	i := p.WorldInertia(inertia)
+	l := i.Mul3x1(p.AngularVelocity)
+	return i.Inv().Mul3x1(torque.Sub(p.AngularVelocity.Cross(l)))
+}
+
+// Acceleration of p due to the gravity of a, independent of the mass of p.
+func (p *Particle) GravitationalAccelerationV(a *Particle, k *Constants) mgl64.Vec3 {
+	deltaPosition := a.Position.Sub(p.Position)
+	distanceSquared := deltaPosition.LenSqr()
+
+	return deltaPosition.Normalize().Mul(k.G * a.Mass / distanceSquared)
+}
+
+// First post-newtonian correction to the acceleration of p in the field of a, in the schwarzschild
+// approximation of a test particle around a much heavier body.
+func (p *Particle) RelativisticAccelerationV(a *Particle, k *Constants) mgl64.Vec3 {
+	r := p.Position.Sub(a.Position)
+	v := p.Velocity.Sub(a.Velocity)
+	d := r.Len()
+	gm := k.G * a.Mass
+	return r.Mul(4*gm/d - v.LenSqr()).Add(v.Mul(4 * r.Dot(v))).Mul(gm / (k.SpeedOfLight * k.SpeedOfLight * d * d * d))
+}
+
+// Acceleration of p by the zonal harmonics of a, on top of the point mass. The axis of a is its +y pole.
+func (p *Particle) ZonalAccelerationV(a *Particle, z *Zonal, c *Constants) mgl64.Vec3 {
+	r := p.Position.Sub(a.Position)
+	d := r.Len()
+	n := r.Mul(1 / d)
+	k := a.Orientation.Rotate(mgl64.Vec3{0, 1, 0})
+	u := n.Dot(k)
+
+	// legendre polynomials and their derivatives in u
+	pl := [5]float64{1, u, (3*u*u - 1) / 2, (5*u*u*u - 3*u) / 2, (35*u*u*u*u - 30*u*u + 3) / 8}
+	dpl := [5]float64{0, 1, 3 * u, (15*u*u - 3) / 2, (35*u*u*u - 15*u) / 2}
+
+	var acc mgl64.Vec3
+	for l := 2; l < len(z.J); l++ {
+		if z.J[l] == 0 {
+			continue
+		}
+		f := c.G * a.Mass * z.J[l] * math.Pow(z.Radius/d, float64(l)) / (d * d)
+		acc = acc.Add(n.Mul(f * (float64(l+1)*pl[l] + u*dpl[l]))).Sub(k.Mul(f * dpl[l]))
+	}
+	return acc
+}
+
+// Gravitational parameter of the relative motion of p around a.
+func (p *Particle) Mu(a *Particle, k *Constants) float64 {
+	if p.Tracer {
+		return k.G * a.Mass
+	}
+	return k.G * (p.Mass + a.Mass)
+}
+
 func (p *Particle) DampenedSpringForceV(a *Particle, l *Link) mgl64.Vec3 {
 	deltaPosition := a.Position.Sub(p.Position)
 	distance := deltaPosition.Len()
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// An asymmetric top without torques precesses and nutates, but keeps its angular momentum
+// in the world frame and its rotational energy.
+func TestTorqueFreePrecession(t *testing.T) {
+	q := mgl64.QuatRotate(0.3, mgl64.Vec3{1, 2, 3}.Normalize())
+	p := Particle{
+		Mass:            100,
+		Orientation:     q,
+		AngularVelocity: q.Rotate(mgl64.Vec3{0.05, 0.4, 0.1}),
+	}
+	bodies := []Body{{Inertia: mgl64.Diag3(mgl64.Vec3{30, 70, 50})}}
+	momentum := func(p *Particle) mgl64.Vec3 { return p.WorldInertia(bodies[0].Inertia).Mul3x1(p.AngularVelocity) }
+	energy := func(p *Particle) float64 { return p.AngularVelocity.Dot(momentum(p)) / 2 }
+
+	ps := ParticleSystem{p}
+	l0, e0 := momentum(&ps[0]), energy(&ps[0])
+	w := newRK4Workspace(len(ps))
+	d := NewDynamics(Config{}, ps, bodies, Layout{}, siConstants)
+	const h = 0.01
+	var turned float64 // largest angle between the angular velocity and the momentum
+	for i := 0; i < 20000; i++ {
+		stepParticleSystem(&w, d.Derivative, h, &ps)
+		turned = math.Max(turned, angleBetween(ps[0].AngularVelocity, l0))
+	}
+
+	if d := momentum(&ps[0]).Sub(l0).Len(); d > 1e-8*l0.Len() {
+		t.Errorf("angular momentum changed by %e of %e", d, l0.Len())
+	}
+	if d := math.Abs(energy(&ps[0]) - e0); d > 1e-8*e0 {
+		t.Errorf("rotational energy changed by %e of %e", d, e0)
+	}
+	// the rotation axis moves around the fixed angular momentum
+	if turned < 0.05 {
+		t.Errorf("angular velocity stayed within %v rad of the angular momentum", turned)
+	}
+}
+
+// A constant torque about a principal axis spins a body up uniformly about that axis.
+func TestConstantTorque(t *testing.T) {
+	q := mgl64.QuatRotate(0.3, mgl64.Vec3{1, 2, 3}.Normalize())
+	ps := ParticleSystem{{Mass: 100, Orientation: q}}
+	bodies := []Body{{Inertia: mgl64.Diag3(mgl64.Vec3{30, 70, 50})}}
+	c := Config{Bodies: []Celestialbody{{Torque: []float64{0, 7, 0}}}}
+	d := NewDynamics(c, ps, bodies, Layout{Bodies: Range{0, 1}}, siConstants)
+
+	w := newRK4Workspace(len(ps))
+	const h, steps = 0.01, 1000
+	for i := 0; i < steps; i++ {
+		stepParticleSystem(&w, d.Derivative, h, &ps)
+	}
+
+	axis := q.Rotate(mgl64.Vec3{0, 1, 0})
+	const alpha, time = 7.0 / 70, h * steps
+	if dw := ps[0].AngularVelocity.Sub(axis.Mul(alpha * time)).Len(); dw > 1e-9 {
+		t.Errorf("angular velocity %v off by %e", ps[0].AngularVelocity, dw)
+	}
+	want := mgl64.QuatRotate(alpha*time*time/2, axis).Mul(q)
+	if dq := math.Abs(ps[0].Orientation.Dot(want)); 1-dq > 1e-9 {
+		t.Errorf("orientation %v, want %v", ps[0].Orientation, want)
+	}
+}
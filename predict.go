@@ -0,0 +1,137 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+const (
+	defaultPredictionSteps = 2000
+	defaultPredictionStep  = 21600.0 // six simulated hours
+
+	// a body deviating from its predicted position by more than this fraction of its
+	// travel per step triggers a new prediction, unless the deviation is too small to see
+	predictionTolerance    = 0.1
+	predictionMinDeviation = 1e6 // meters
+)
+
+type PredictionConfig struct {
+	Steps int
+	Step  float64 // simulated seconds per step
+}
+
+type prediction struct {
+	start      float64        // simulated time of the first sample
+	paths      [][]mgl64.Vec3 // positions of each body, one per step
+	framed     [][]mgl64.Vec3 // the same in the coordinates of the frame at each step
+	generation int
+}
+
+// Predictor integrates a copy of the system ahead of time in the background.
+type Predictor struct {
+	steps      int
+	step       float64
+	schedule   *Schedule
+	dynamics   *Dynamics // of the predicted bodies
+	frame      *Frame    // the paths are drawn in
+	current    *prediction
+	results    chan *prediction
+	running    bool
+	generation int // predictions of an older generation are dropped
+}
+
+// The prediction follows the maneuvers of the schedule with the dynamics of the predicted bodies. Its paths
+// are kept in the frame as well, which has to be invalidated when the frame changes.
+func NewPredictor(c PredictionConfig, schedule *Schedule, dynamics *Dynamics, frame *Frame) *Predictor {
+	return &Predictor{
+		steps:    c.Steps,
+		step:     c.Step,
+		schedule: schedule,
+		dynamics: dynamics,
+		frame:    frame,
+		results:  make(chan *prediction, 1),
+	}
+}
+
+func (p *Predictor) run(s Schedule, f Frame, ps ParticleSystem, start float64, generation int) {
+	w := newRK4Workspace(len(ps))
+	r := prediction{start, make([][]mgl64.Vec3, len(ps)), make([][]mgl64.Vec3, len(ps)), generation}
+	record := func(k int) {
+		fs := f.At(ps)
+		for i := range r.paths {
+			r.paths[i][k] = ps[i].Position
+			r.framed[i][k] = fs.Position(ps[i].Position)
+		}
+	}
+	for i := range r.paths {
+		r.paths[i] = make([]mgl64.Vec3, p.steps+1)
+		r.framed[i] = make([]mgl64.Vec3, p.steps+1)
+	}
+	record(0)
+	for k := 1; k <= p.steps; k++ {
+		s.Step(&w, &ps, start+float64(k-1)*p.step, p.step, nil)
+		record(k)
+	}
+	p.results <- &r
+}
+
+// Reports whether the state at the given time has left the current prediction
+// or the prediction has used up half of its horizon.
+func (p *Predictor) stale(ps ParticleSystem, time float64) bool {
+	if p.current == nil || len(p.current.paths) != len(ps) {
+		return true
+	}
+	x := (time - p.current.start) / p.step
+	k := int(x)
+	if x < 0 || 2*k >= p.steps {
+		return true
+	}
+	for i, path := range p.current.paths {
+		expected := lerp64(path[k], path[k+1], x-float64(k))
+		travel := path[k+1].Sub(path[k]).Len()
+		if ps[i].Position.Sub(expected).Len() > math.Max(predictionTolerance*travel, units.FromSI(predictionMinDeviation, dimLength)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Collects a finished prediction and starts a new one from a copy of ps if the current one is stale.
+func (p *Predictor) Update(ps ParticleSystem, time float64) {
+	select {
+	case r := <-p.results:
+		if r.generation == p.generation {
+			p.current = r
+		}
+		p.running = false
+	default:
+	}
+	if !p.running && p.stale(ps, time) {
+		p.running = true
+		go p.run(p.schedule.With(p.dynamics), *p.frame, append(ParticleSystem(nil), ps...), time, p.generation)
+	}
+}
+
+// Discards the current prediction and any running one, e.g. after the schedule or the frame changed.
+func (p *Predictor) Invalidate() {
+	p.generation++
+	p.current = nil
+}
+
+// Returns the predicted path of every body from the given time on, in coordinates of the frame
+// like the trails. Paths start at an even step, so the dashes of consecutive frames line up.
+func (p *Predictor) Paths(time float64) [][]mgl64.Vec3 {
+	if p.current == nil {
+		return nil
+	}
+	k := int((time-p.current.start)/p.step) + 1
+	k += k % 2
+	r := make([][]mgl64.Vec3, len(p.current.framed))
+	for i, path := range p.current.framed {
+		if k < len(path) {
+			r[i] = path[k:]
+		}
+	}
+	return r
+}
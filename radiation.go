@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+type ShadowModel int
+
+const (
+	ShadowCylindrical ShadowModel = iota // parallel light, the shadow is a cylinder behind the occulter
+	ShadowConical                        // the source is a disk, with umbra and penumbra
+	shadowModels
+)
+
+var shadowModelNames = [shadowModels]string{"cylindrical", "conical"}
+
+func (m ShadowModel) String() string {
+	if m < 0 || m >= shadowModels {
+		return fmt.Sprintf("ShadowModel(%d)", int(m))
+	}
+	return shadowModelNames[m]
+}
+
+func (m *ShadowModel) UnmarshalText(text []byte) error {
+	for i, n := range shadowModelNames {
+		if n == string(text) {
+			*m = ShadowModel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown shadow model %q", text)
+}
+
+// Fraction of the light of the source at s with radius rs that reaches p past the occulter at o with radius ro.
+func illumination(m ShadowModel, p, s, o mgl64.Vec3, rs, ro float64) float64 {
+	ps, po := s.Sub(p), o.Sub(p)
+	ds, do := ps.Len(), po.Len()
+	if do >= ds {
+		return 1
+	}
+	if m == ShadowCylindrical {
+		u := ps.Mul(1 / ds)
+		along := po.Dot(u)
+		if along <= 0 || po.Sub(u.Mul(along)).Len() >= ro {
+			return 1
+		}
+		return 0
+	}
+
+	// apparent radii of the disks and their separation
+	a := math.Asin(math.Min(rs/ds, 1))
+	b := math.Asin(math.Min(ro/do, 1))
+	c := angleBetween(ps, po)
+	switch {
+	case c >= a+b:
+		return 1
+	case c <= b-a:
+		return 0
+	case c <= a-b:
+		return 1 - b*b/(a*a)
+	}
+	x := (c*c + a*a - b*b) / (2 * c)
+	y := math.Sqrt(math.Max(a*a-x*x, 0))
+	overlap := a*a*math.Acos(mgl64.Clamp(x/a, -1, 1)) + b*b*math.Acos(mgl64.Clamp((c-x)/b, -1, 1)) - c*y
+	return 1 - overlap/(math.Pi*a*a)
+}
+
+// Pushes the bodies with an area away from the luminous bodies, unless they are in the shadow of another body.
+// The pressure coefficient is 1 + reflectivity, so a perfect mirror is pushed twice as hard as a black body.
+func (d *Dynamics) radiation(y, dy ParticleSystem) {
+	for k, i := range d.irradiated {
+		if y[i].Mass == 0 {
+			continue
+		}
+		for l, j := range d.luminous {
+			if i == j {
+				continue
+			}
+			light := 1.0
+			for m, o := range d.occulters {
+				if o != i && o != j {
+					light *= illumination(d.shadow, y[i].Position, y[j].Position, y[o].Position, d.sourceRadius[l], d.occulterRadius[m])
+				}
+			}
+			if light == 0 {
+				continue
+			}
+			r := y[i].Position.Sub(y[j].Position)
+			d2 := r.LenSqr()
+			p := light * d.luminosity[l] / (4 * math.Pi * d.Constants.SpeedOfLight * d2)
+			dy[i].Velocity = dy[i].Velocity.Add(r.Mul(p * d.cra[k] / (y[i].Mass * math.Sqrt(d2))))
+		}
+	}
+}
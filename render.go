@@ -24,32 +24,101 @@ type VAO struct {
 	count int32
 }
 
+// first attribute location of the per-instance model matrix, which occupies four locations
+const instanceLocation = 3
+
+// the shader supports at most this many emissive bodies as light sources
+const maxLights = 4
+
 type Scene struct {
-	camera  *Camera
-	objects []Object
+	camera    *Camera
+	objects   []Object
+	instances VBO
+	lighting  LightingUniforms
 }
 
 type Object struct {
 	Transform mgl64.Mat4
 	texture   uint32
 	Vao       VAO
+	Emissive  bool // lights the other objects and is not lit itself
+}
+
+// Batch is a group of objects sharing mesh and texture, which is drawn with a single instanced call.
+type Batch struct {
+	texture    uint32
+	Vao        VAO
+	Emissive   bool
+	Transforms []mgl32.Mat4
 }
 
-func ConstructVBO(vertices []mgl64.Vec3, uvcoords []mgl64.Vec2) VBO {
-	if len(vertices) != len(uvcoords) {
-		log.Fatal("mismatch in amount of vertices and uvcoords")
+type batchKey struct {
+	vao      uint32
+	texture  uint32
+	emissive bool
+}
+
+// Groups the objects by mesh, texture and emissiveness, keeping the order of first appearance.
+// The transforms are made relative to the camera.
+func batchObjects(c *Camera, objects []Object) []Batch {
+	index := make(map[batchKey]int)
+	var r []Batch
+	for _, o := range objects {
+		k := batchKey{o.Vao.vao, o.texture, o.Emissive}
+		j, ok := index[k]
+		if !ok {
+			j = len(r)
+			index[k] = j
+			r = append(r, Batch{o.texture, o.Vao, o.Emissive, nil})
+		}
+		r[j].Transforms = append(r[j].Transforms, c.Model(o.Transform))
+	}
+	return r
+}
+
+// Positions of the emissive objects relative to the camera, as used by the shader.
+func lights(c *Camera, objects []Object) []mgl32.Vec3 {
+	var r []mgl32.Vec3
+	for _, o := range objects {
+		if o.Emissive && len(r) < maxLights {
+			r = append(r, c.Model(o.Transform).Col(3).Vec3())
+		}
+	}
+	return r
+}
+
+type LightingUniforms struct {
+	lights     int32
+	lightCount int32
+	emissive   int32
+}
+
+func getLightingUniforms(program uint32) LightingUniforms {
+	return LightingUniforms{
+		gl.GetUniformLocation(program, gl.Str("lights\x00")),
+		gl.GetUniformLocation(program, gl.Str("lightCount\x00")),
+		gl.GetUniformLocation(program, gl.Str("emissive\x00")),
+	}
+}
+
+func ConstructVBO(vertices []mgl64.Vec3, normals []mgl64.Vec3, uvcoords []mgl64.Vec2) VBO {
+	if len(vertices) != len(uvcoords) || len(vertices) != len(normals) {
+		log.Fatal("mismatch in amount of vertices, normals and uvcoords")
 	}
 
 	var r uint32
-	a := make([][5]float32, len(uvcoords))
+	a := make([][8]float32, len(uvcoords))
 	fv := make([]float32, len(vertices[0]))
+	fn := make([]float32, len(normals[0]))
 	fuv := make([]float32, len(uvcoords[0]))
 	for i := range vertices {
 		dv := vertices[i][:]
 		Arrayf64Tof32(dv, &fv)
+		dn := normals[i][:]
+		Arrayf64Tof32(dn, &fn)
 		duv := uvcoords[i][:]
 		Arrayf64Tof32(duv, &fuv)
-		a[i] = [5]float32{fv[0], fv[1], fv[2], fuv[0], fuv[1]}
+		a[i] = [8]float32{fv[0], fv[1], fv[2], fuv[0], fuv[1], fn[0], fn[1], fn[2]}
 	}
 
 	gl.GenBuffers(1, &r)
@@ -64,6 +133,13 @@ func ConstructVBO(vertices []mgl64.Vec3, uvcoords []mgl64.Vec2) VBO {
 	return VBO(r)
 }
 
+// buffer for the per-instance data, which is refilled on every draw
+func ConstructInstanceVBO() VBO {
+	var r uint32
+	gl.GenBuffers(1, &r)
+	return VBO(r)
+}
+
 func ConstructEBO(faces []Surface) EBO {
 	var r uint32
 	gl.GenBuffers(1, &r)
@@ -86,10 +162,12 @@ func ConstructVAO(vbo VBO, ebo EBO) VAO {
 	gl.BindBuffer(gl.ARRAY_BUFFER, uint32(vbo))
 	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ebo.ebo)
 
-	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, int32(unsafe.Sizeof([5]float32{})), nil)
-	gl.VertexAttribPointer(1, 2, gl.FLOAT, false, int32(unsafe.Sizeof([5]float32{})), unsafe.Pointer(unsafe.Sizeof([3]float32{})))
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, int32(unsafe.Sizeof([8]float32{})), nil)
+	gl.VertexAttribPointer(1, 2, gl.FLOAT, false, int32(unsafe.Sizeof([8]float32{})), gl.PtrOffset(int(unsafe.Sizeof([3]float32{}))))
+	gl.VertexAttribPointer(2, 3, gl.FLOAT, false, int32(unsafe.Sizeof([8]float32{})), gl.PtrOffset(int(unsafe.Sizeof([5]float32{}))))
 	gl.EnableVertexAttribArray(0)
 	gl.EnableVertexAttribArray(1)
+	gl.EnableVertexAttribArray(2)
 
 	gl.BindVertexArray(0)
 
@@ -97,7 +175,7 @@ func ConstructVAO(vbo VBO, ebo EBO) VAO {
 }
 
 func (m *Mesh) Load() VAO {
-	vbo := ConstructVBO(m.Vertices, m.UVcoords)
+	vbo := ConstructVBO(m.Vertices, m.Normals, m.UVcoords)
 	ebo := ConstructEBO(m.Faces)
 
 	return ConstructVAO(vbo, ebo)
@@ -147,16 +225,40 @@ func (v *VAO) Draw() {
 	gl.BindVertexArray(0)
 }
 
-func (s *Scene) Draw(viewUni int32) {
-	m := s.camera.Perspective()
-	d := make([]float32, len(mgl32.Mat4{}))
-	for _, o := range s.objects {
-		mo := m.Mul4(o.Transform)
-		Arrayf64Tof32(mo[:], &d)
-		gl.UniformMatrix4fv(viewUni, 1, false, &d[0])
+func (b *Batch) Draw(instances VBO) {
+	gl.BindVertexArray(b.Vao.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, uint32(instances))
+	gl.BufferData(
+		gl.ARRAY_BUFFER,
+		int(unsafe.Sizeof(b.Transforms[0]))*len(b.Transforms),
+		unsafe.Pointer(&b.Transforms[0]),
+		gl.STREAM_DRAW,
+	)
+	for i := 0; i < 4; i++ {
+		l := uint32(instanceLocation + i)
+		gl.VertexAttribPointer(l, 4, gl.FLOAT, false, int32(unsafe.Sizeof(mgl32.Mat4{})), gl.PtrOffset(i*int(unsafe.Sizeof(mgl32.Vec4{}))))
+		gl.VertexAttribDivisorARB(l, 1)
+		gl.EnableVertexAttribArray(l)
+	}
+	gl.DrawElementsInstancedARB(gl.TRIANGLES, int32(b.Vao.count*3), gl.UNSIGNED_INT, nil, int32(len(b.Transforms)))
+	gl.BindVertexArray(0)
+}
 
+func (s *Scene) Draw(u Uniforms) {
+	u.Set(s.camera)
+	l := lights(s.camera, s.objects)
+	if len(l) > 0 {
+		gl.Uniform3fv(s.lighting.lights, int32(len(l)), &l[0][0])
+	}
+	gl.Uniform1i(s.lighting.lightCount, int32(len(l)))
+	for _, b := range batchObjects(s.camera, s.objects) {
+		emissive := int32(0)
+		if b.Emissive {
+			emissive = 1
+		}
+		gl.Uniform1i(s.lighting.emissive, emissive)
 		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, o.texture)
-		o.Vao.Draw()
+		gl.BindTexture(gl.TEXTURE_2D, b.texture)
+		b.Draw(s.instances)
 	}
 }
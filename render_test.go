@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+func TestBatchObjects(t *testing.T) {
+	sphere, rock := VAO{1, 960}, VAO{2, 60}
+	// the x translation of each object identifies it in the batches
+	object := func(x float64, texture uint32, vao VAO, emissive bool) Object {
+		return Object{mgl64.Translate3D(x, 0, 0), texture, vao, emissive}
+	}
+	type batch struct {
+		texture  uint32
+		vao      uint32
+		emissive bool
+		objects  []float64
+	}
+	cases := []struct {
+		name    string
+		objects []Object
+		want    []batch
+	}{
+		{"empty", nil, nil},
+		{
+			"distinct textures",
+			[]Object{object(1, 10, sphere, true), object(2, 11, sphere, false), object(3, 12, sphere, false)},
+			[]batch{{10, 1, true, []float64{1}}, {11, 1, false, []float64{2}}, {12, 1, false, []float64{3}}},
+		},
+		{
+			"shared texture and mesh",
+			[]Object{object(1, 10, sphere, false), object(2, 20, rock, false), object(3, 20, rock, false), object(4, 10, sphere, false), object(5, 20, rock, false)},
+			[]batch{{10, 1, false, []float64{1, 4}}, {20, 2, false, []float64{2, 3, 5}}},
+		},
+		{
+			"same texture on different meshes",
+			[]Object{object(1, 10, rock, false), object(2, 10, sphere, false), object(3, 10, rock, false)},
+			[]batch{{10, 2, false, []float64{1, 3}}, {10, 1, false, []float64{2}}},
+		},
+		{
+			"emissive apart",
+			[]Object{object(1, 10, sphere, false), object(2, 10, sphere, true), object(3, 10, sphere, false)},
+			[]batch{{10, 1, false, []float64{1, 3}}, {10, 1, true, []float64{2}}},
+		},
+	}
+	c := testCamera(mgl64.Vec3{}, false)
+	for _, tc := range cases {
+		got := batchObjects(c, tc.objects)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: %d batches, want %d", tc.name, len(got), len(tc.want))
+			continue
+		}
+		for i, w := range tc.want {
+			b := got[i]
+			if b.texture != w.texture || b.Vao.vao != w.vao || b.Emissive != w.emissive || len(b.Transforms) != len(w.objects) {
+				t.Errorf("%s: batch %d is %v/%v/%v with %d objects, want %v", tc.name, i, b.texture, b.Vao.vao, b.Emissive, len(b.Transforms), w)
+				continue
+			}
+			for j, x := range w.objects {
+				if got := float64(b.Transforms[j][12]); got != x {
+					t.Errorf("%s: batch %d object %d is %v, want %v", tc.name, i, j, got, x)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+const (
+	defaultScaleFactor = 10.0
+	defaultMinPixels   = 3.0
+)
+
+type ScaleMode int
+
+const (
+	ScaleTrue        ScaleMode = iota // bodies are drawn with their physical radius
+	ScaleExaggerated                  // radii are multiplied by a fixed factor
+	ScaleMinPixels                    // bodies are never drawn smaller than a number of pixels
+	scaleModes
+)
+
+var scaleModeNames = [scaleModes]string{"true", "exaggerated", "min_pixels"}
+
+func (m ScaleMode) String() string {
+	if m < 0 || m >= scaleModes {
+		return fmt.Sprintf("ScaleMode(%d)", int(m))
+	}
+	return scaleModeNames[m]
+}
+
+func (m *ScaleMode) UnmarshalText(text []byte) error {
+	for i, n := range scaleModeNames {
+		if n == string(text) {
+			*m = ScaleMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown scale mode %q", text)
+}
+
+// VisualScale decides how large a body is drawn, it does not affect the physics.
+type VisualScale struct {
+	Mode      ScaleMode
+	Factor    float64 // used by ScaleExaggerated
+	MinPixels float64 `toml:"min_pixels"` // used by ScaleMinPixels
+}
+
+// Radius to draw a body of radius r with, which is seen from distance d.
+func (s *VisualScale) Radius(r, d float64, c *Camera) float64 {
+	switch s.Mode {
+	case ScaleExaggerated:
+		return r * s.Factor
+	case ScaleMinPixels:
+		return math.Max(r, s.MinPixels*c.PixelSize(d))
+	}
+	return r
+}
+
+// Model matrix of a body of radius r, in world space scaled by glCorrectionScale.
+// The sphere mesh has its pole along z, which is turned onto the +y pole of the body frame.
+func bodyTransform(b *Particle, r float64, s *VisualScale, c *Camera) mgl64.Mat4 {
+	r = s.Radius(r, b.Position.Sub(*c.position).Len(), c) * glCorrectionScale
+	p := b.Position.Mul(glCorrectionScale)
+	return mgl64.Translate3D(p[0], p[1], p[2]).Mul4(mgl64.Scale3D(r, r, r)).Mul4(b.Orientation.Mat4()).Mul4(mgl64.HomogRotate3D(-math.Pi/2, mgl64.Vec3{1, 0, 0}))
+}
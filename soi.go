@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Laplace radius of the sphere of influence of every body, in which its gravity dominates the motion
+// around its own primary. The spheres are nested along the hierarchy, the root has an infinite sphere.
+func soiRadii(ps ParticleSystem, hierarchy []int) []float64 {
+	r := make([]float64, len(ps))
+	for i := range ps {
+		k := hierarchy[i]
+		switch {
+		case k < 0:
+			r[i] = math.Inf(1)
+		case ps[i].Tracer || ps[k].Mass == 0:
+			r[i] = 0
+		default:
+			a := ps[i].Position.Sub(ps[k].Position).Len()
+			r[i] = a * math.Pow(ps[i].Mass/ps[k].Mass, 0.4)
+		}
+	}
+	return r
+}
+
+// SOICrossing is the passage of a body from the sphere of influence of one primary into another.
+type SOICrossing struct {
+	Time float64
+	Body int
+	From int
+	To   int
+}
+
+// SOITracker labels every body with its dominant primary, the body with the smallest
+// sphere of influence it is in.
+type SOITracker struct {
+	hierarchy []int // configured primaries
+	Radii     []float64
+	Current   []int // dominant primary of every body, -1 for the root
+}
+
+func NewSOITracker(ps ParticleSystem, hierarchy []int) *SOITracker {
+	s := &SOITracker{hierarchy: hierarchy}
+	s.Current = make([]int, len(ps))
+	s.update(ps)
+	return s
+}
+
+func (s *SOITracker) update(ps ParticleSystem) {
+	s.Radii = soiRadii(ps, s.hierarchy)
+	var candidates []int
+	for j, r := range s.Radii {
+		if r > 0 {
+			candidates = append(candidates, j)
+		}
+	}
+	for i := range ps {
+		s.Current[i] = -1
+		for _, j := range candidates {
+			if j == i {
+				continue
+			}
+			in := ps[i].Position.Sub(ps[j].Position).Len() < s.Radii[j]
+			if in && (s.Current[i] == -1 || s.Radii[j] < s.Radii[s.Current[i]]) {
+				s.Current[i] = j
+			}
+		}
+	}
+}
+
+// Relabels the bodies at the simulated time t and returns the crossings since the last update.
+func (s *SOITracker) Update(ps ParticleSystem, t float64) []SOICrossing {
+	previous := append([]int(nil), s.Current...)
+	s.update(ps)
+	var r []SOICrossing
+	for i := range s.Current {
+		if s.Current[i] != previous[i] {
+			r = append(r, SOICrossing{t, i, previous[i], s.Current[i]})
+		}
+	}
+	return r
+}
+
+// The crossing as an occurrence for the detector, with a copy of the state ps at its time.
+func (c SOICrossing) Occurrence(ps ParticleSystem, bodies []Body) Occurrence {
+	e := &Event{
+		Bodies: []int{c.Body},
+		Rising: fmt.Sprintf("%s left the sphere of influence of %s for %s", bodies[c.Body].Name, primaryName(bodies, c.From), primaryName(bodies, c.To)),
+	}
+	return Occurrence{c.Time, e, true, append(ParticleSystem(nil), ps...)}
+}
+
+func primaryName(bodies []Body, k int) string {
+	if k < 0 {
+		return "none"
+	}
+	return bodies[k].Name
+}
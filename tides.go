@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+const defaultInertiaFactor = 0.4 // of a homogeneous sphere
+
+// Tides raised on a body by the other massive bodies, which lag behind with a constant time lag.
+type Tides struct {
+	Love          float64 // second degree love number k2
+	Q             float64 // quality factor, sets the time lag at the initial tidal frequency
+	TimeLag       float64 `toml:"time_lag"`       // overrides Q
+	InertiaFactor float64 `toml:"inertia_factor"` // moment of inertia over M R², for bodies without an inertia tensor
+}
+
+// tidal interaction of a body and one perturber
+type tidalPair struct {
+	body      int
+	perturber int
+	love      float64
+	lag       float64
+	radius    float64
+}
+
+// Time lag for the quality factor q at the semidiurnal tidal frequency 2|Ω - n| of the perturber p raising tides on b,
+// where Ω is the spin of b about the orbit normal of p, negative if p orbits against the spin.
+func timeLag(b, p *Particle, q float64, k *Constants) float64 {
+	r := p.Position.Sub(b.Position)
+	h := r.Cross(p.Velocity.Sub(b.Velocity))
+	n := math.Sqrt(k.G * (b.Mass + p.Mass) / (r.Len() * r.LenSqr()))
+	f := 2 * math.Abs(b.AngularVelocity.Dot(h.Normalize())-n)
+	if f == 0 {
+		return 0
+	}
+	return 1 / (q * f)
+}
+
+// Force on p by the tides it raises on b, which has the love number k2, the radius r and lags by dt.
+// After Mignard, the lag turns the bulge ahead of p while b spins faster than p orbits and behind it otherwise.
+func tidalForce(b, p *Particle, k2, r, dt float64, k *Constants) mgl64.Vec3 {
+	d := p.Position.Sub(b.Position)
+	v := p.Velocity.Sub(b.Velocity)
+	l2 := d.LenSqr()
+	lag := d.Mul(2 * d.Dot(v) / l2).Add(d.Cross(b.AngularVelocity)).Add(v).Mul(dt)
+	return d.Add(lag).Mul(-3 * k2 * k.G * p.Mass * p.Mass * math.Pow(r, 5) / (l2 * l2 * l2 * l2))
+}
+
+// Applies the tidal forces and adds the opposite torque to the tidal bodies,
+// so angular momentum moves between spin and orbit.
+func (d *Dynamics) tides(y, dy ParticleSystem) {
+	for _, t := range d.tidal {
+		b, p := &y[t.body], &y[t.perturber]
+		f := tidalForce(b, p, t.love, t.radius, t.lag, &d.Constants)
+		dy[t.perturber].Velocity = dy[t.perturber].Velocity.Add(f.Mul(1 / p.Mass))
+		dy[t.body].Velocity = dy[t.body].Velocity.Sub(f.Mul(1 / b.Mass))
+
+		torque := p.Position.Sub(b.Position).Cross(f).Mul(-1)
+		dy[t.body].AngularVelocity = dy[t.body].AngularVelocity.Add(torque)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// The tides the moon raises on the faster spinning earth lead the moon and push it outwards,
+// while the earth spins down and the total angular momentum stays.
+func TestTidalRecession(t *testing.T) {
+	const (
+		radius = 6.3781e6
+		a      = 20 * radius // close, so the recession shows within weeks
+		factor = 0.3307
+		h      = 60.0
+		days   = 20
+	)
+	earth := Celestialbody{Name: "earth", Mass: 5.9722e24, Diameter: 2 * radius, RotationPeriod: 86164, Tides: &Tides{Love: 0.3, Q: 12, InertiaFactor: factor}}
+	moon := Celestialbody{Name: "moon", Mass: 7.342e22, Distance: a, Diameter: 3.4748e6}
+	moon.Speed = math.Sqrt(G * (earth.Mass + moon.Mass) / a)
+	c := Config{Bodies: []Celestialbody{earth, moon}}
+	ps := ParticleSystem{newParticle(earth), newParticle(moon)}
+	d := NewDynamics(c, ps, nil, Layout{Bodies: Range{0, len(ps)}}, siConstants)
+	inertia := factor * earth.Mass * radius * radius
+
+	momentum := func() mgl64.Vec3 {
+		l := ps[0].AngularVelocity.Mul(inertia)
+		for _, p := range ps {
+			l = l.Add(p.Position.Cross(p.Velocity).Mul(p.Mass))
+		}
+		return l
+	}
+	a0, spin0, l0 := ps[1].Elements(&ps[0], &d.Constants).SemiMajorAxis, ps[0].AngularVelocity.Len(), momentum()
+	w := newRK4Workspace(len(ps))
+	for i := 0; i < days*secondsPerDay/h; i++ {
+		stepParticleSystem(&w, d.Derivative, h, &ps)
+	}
+	a1, spin1, l1 := ps[1].Elements(&ps[0], &d.Constants).SemiMajorAxis, ps[0].AngularVelocity.Len(), momentum()
+
+	// the tangential force of the bulge, which leads by the lag at the tidal frequency 2(Ω - n)
+	n := math.Sqrt(G * (earth.Mass + moon.Mass) / (a * a * a))
+	spin := 2 * math.Pi / earth.RotationPeriod
+	lag := 1 / (earth.Tides.Q * 2 * (spin - n))
+	f := 3 * earth.Tides.Love * G * moon.Mass * moon.Mass * math.Pow(radius, 5) / math.Pow(a, 7) * (spin - n) * lag
+	want := 2 * f / (moon.Mass * n) * days * secondsPerDay
+	if math.Abs(a1-a0-want) > 0.1*want {
+		t.Errorf("semi-major axis grew by %.3f m, want %.3f m", a1-a0, want)
+	}
+	if spin1 >= spin0 {
+		t.Errorf("spin went from %e rad/s to %e rad/s", spin0, spin1)
+	}
+	spun := inertia * (spin0 - spin1)
+	if dl := l1.Sub(l0).Len(); dl > 1e-3*spun {
+		t.Errorf("angular momentum changed by %e while the spin lost %e", dl, spun)
+	}
+}
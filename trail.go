@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+const (
+	defaultTrailLength   = 1000
+	defaultTrailInterval = 86400.0 // one sample per simulated day
+)
+
+type TrailConfig struct {
+	Length   int     // samples kept per body
+	Interval float64 // simulated seconds between samples
+}
+
+// Trail is a ring buffer of the most recent positions of a body, sampled by simulated time.
+type Trail struct {
+	points   []mgl64.Vec3
+	start    int // index of the oldest sample
+	count    int
+	interval float64
+	last     float64 // simulated time of the newest sample
+}
+
+func NewTrail(length int, interval float64) *Trail {
+	return &Trail{points: make([]mgl64.Vec3, length), interval: interval}
+}
+
+// Stores p if at least one interval has passed since the last sample, overwriting the oldest sample when full.
+func (t *Trail) Record(time float64, p mgl64.Vec3) {
+	if t.count > 0 && time-t.last < t.interval {
+		return
+	}
+	t.last = time
+	if t.count < len(t.points) {
+		t.points[(t.start+t.count)%len(t.points)] = p
+		t.count++
+		return
+	}
+	t.points[t.start] = p
+	t.start = (t.start + 1) % len(t.points)
+}
+
+func (t *Trail) Len() int {
+	return t.count
+}
+
+// Returns the i-th sample, where 0 is the oldest.
+func (t *Trail) At(i int) mgl64.Vec3 {
+	return t.points[(t.start+i)%len(t.points)]
+}
+
+func (t *Trail) Clear() {
+	t.start = 0
+	t.count = 0
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+func point(x float64) mgl64.Vec3 {
+	return mgl64.Vec3{x, 0, 0}
+}
+
+// samples of the trail, oldest first
+func samples(t *Trail) []float64 {
+	var r []float64
+	for i := 0; i < t.Len(); i++ {
+		r = append(r, t.At(i)[0])
+	}
+	return r
+}
+
+func equalFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTrailWrapAround(t *testing.T) {
+	tr := NewTrail(3, 1)
+	cases := []struct {
+		record float64
+		want   []float64
+	}{
+		{0, []float64{0}},
+		{1, []float64{0, 1}},
+		{2, []float64{0, 1, 2}},
+		{3, []float64{1, 2, 3}},
+		{4, []float64{2, 3, 4}},
+		{5, []float64{3, 4, 5}},
+		{6, []float64{4, 5, 6}},
+	}
+	for _, c := range cases {
+		tr.Record(c.record, point(c.record))
+		if got := samples(tr); !equalFloats(got, c.want) {
+			t.Errorf("after recording %v: %v, want %v", c.record, got, c.want)
+		}
+		if tr.Len() != len(c.want) {
+			t.Errorf("after recording %v: length %d, want %d", c.record, tr.Len(), len(c.want))
+		}
+	}
+}
+
+func TestTrailClear(t *testing.T) {
+	tr := NewTrail(3, 1)
+	for i := 0.0; i < 5; i++ {
+		tr.Record(i, point(i))
+	}
+	tr.Clear()
+	if tr.Len() != 0 {
+		t.Fatalf("length %d after clear", tr.Len())
+	}
+	// the first sample after clearing is taken even within the interval
+	tr.Record(4.5, point(7))
+	tr.Record(5.5, point(8))
+	if got, want := samples(tr), []float64{7, 8}; !equalFloats(got, want) {
+		t.Errorf("after clear: %v, want %v", got, want)
+	}
+}
+
+func TestTrailInterval(t *testing.T) {
+	tr := NewTrail(10, 10)
+	for i := 0.0; i <= 35; i += 2.5 {
+		tr.Record(i, point(i))
+	}
+	if got, want := samples(tr), []float64{0, 10, 20, 30}; !equalFloats(got, want) {
+		t.Errorf("thinned to %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+const (
+	porkchopPath           = "porkchop.csv"
+	defaultTransferSamples = 50
+)
+
+// TransferConfig describes the window scanned for transfers between two bodies orbiting the same primary.
+// Times are in simulated seconds from the moment the scan is started.
+type TransferConfig struct {
+	From      string
+	To        string
+	Body      string    // receives the cheapest transfer as a maneuver, should be close to From
+	Departure []float64 // earliest and latest departure
+	Arrival   []float64 // earliest and latest arrival
+	Samples   int       // per axis of the grid
+}
+
+// Porkchop is the grid of delta-v needed for transfers by departure and arrival time.
+type Porkchop struct {
+	Departures []float64 // simulated times
+	Arrivals   []float64
+	DeltaV     [][]float64 // departure plus arrival burn, NaN where no transfer exists
+	burns      [][]mgl64.Vec3
+	states     []ParticleSystem // of the system at the departures
+	from       int
+	to         int
+	central    int
+}
+
+func linspace(a, b float64, n int) []float64 {
+	r := make([]float64, n)
+	for i := range r {
+		r[i] = a
+		if n > 1 {
+			r[i] += (b - a) * float64(i) / float64(n-1)
+		}
+	}
+	return r
+}
+
+// Integrates a copy of ps from the time t with the schedule and returns its state at each
+// of the ascending times, using steps of at most step.
+func sampleStates(s Schedule, ps ParticleSystem, t float64, times []float64, step float64) []ParticleSystem {
+	ps = append(ParticleSystem(nil), ps...)
+	w := newRK4Workspace(len(ps))
+	r := make([]ParticleSystem, len(times))
+	for i, e := range times {
+		n := int(math.Ceil((e - t) / step))
+		h := (e - t) / float64(n)
+		for k := 0; k < n; k++ {
+			s.Step(&w, &ps, t, h, nil)
+			t += h
+		}
+		t = e
+		r[i] = append(ParticleSystem(nil), ps...)
+	}
+	return r
+}
+
+// Scans the transfer window over the ephemeris of ps, which is integrated ahead from the time t.
+func NewPorkchop(c TransferConfig, s Schedule, ps ParticleSystem, bodies []Body, prim []int, t, step float64) (*Porkchop, error) {
+	var p Porkchop
+	p.from, p.to = -1, -1
+	for i := range ps {
+		if bodies[i].Name == c.From {
+			p.from = i
+		}
+		if bodies[i].Name == c.To {
+			p.to = i
+		}
+	}
+	if p.from == -1 || p.to == -1 {
+		return nil, fmt.Errorf("transfer between unknown bodies %q and %q", c.From, c.To)
+	}
+	if p.central = prim[p.from]; p.central == -1 {
+		return nil, fmt.Errorf("transfer from %s, which has no primary", c.From)
+	}
+	if len(c.Departure) != 2 || len(c.Arrival) != 2 {
+		return nil, fmt.Errorf("transfer window needs a start and an end for departure and arrival")
+	}
+
+	p.Departures = linspace(t+c.Departure[0], t+c.Departure[1], c.Samples)
+	p.Arrivals = linspace(t+c.Arrival[0], t+c.Arrival[1], c.Samples)
+	times := append(append([]float64(nil), p.Departures...), p.Arrivals...)
+	sort.Float64s(times)
+	states := sampleStates(s, ps, t, times, step)
+	at := func(e float64) ParticleSystem {
+		return states[sort.SearchFloat64s(times, e)]
+	}
+
+	mu := s.dynamics.Constants.G * ps[p.central].Mass
+	p.DeltaV = make([][]float64, len(p.Departures))
+	p.burns = make([][]mgl64.Vec3, len(p.Departures))
+	p.states = make([]ParticleSystem, len(p.Departures))
+	for i, d := range p.Departures {
+		p.DeltaV[i] = make([]float64, len(p.Arrivals))
+		p.burns[i] = make([]mgl64.Vec3, len(p.Arrivals))
+		p.states[i] = at(d)
+		dep := p.states[i]
+		r1 := dep[p.from].Position.Sub(dep[p.central].Position)
+		v1 := dep[p.from].Velocity.Sub(dep[p.central].Velocity)
+		for j, a := range p.Arrivals {
+			p.DeltaV[i][j] = math.NaN()
+			arr := at(a)
+			r2 := arr[p.to].Position.Sub(arr[p.central].Position)
+			v2 := arr[p.to].Velocity.Sub(arr[p.central].Velocity)
+			tv1, tv2, err := Lambert(r1, r2, a-d, mu, r1.Cross(v1))
+			if err != nil {
+				continue
+			}
+			p.burns[i][j] = tv1.Sub(v1)
+			p.DeltaV[i][j] = tv1.Sub(v1).Len() + v2.Sub(tv2).Len()
+		}
+	}
+	return &p, nil
+}
+
+// Indices of the departure and arrival of the cheapest transfer, -1 if there is none.
+func (p *Porkchop) Best() (int, int) {
+	bi, bj := -1, -1
+	for i := range p.DeltaV {
+		for j, dv := range p.DeltaV[i] {
+			if !math.IsNaN(dv) && (bi == -1 || dv < p.DeltaV[bi][bj]) {
+				bi, bj = i, j
+			}
+		}
+	}
+	return bi, bj
+}
+
+// The departure burn of the transfer as an impulsive maneuver of the given body. It matches the
+// velocity of the body to the transfer orbit, so the body should travel along with the departure body.
+func (p *Porkchop) Maneuver(i, j int, name string, bodies []Body) (Maneuver, error) {
+	ps := p.states[i]
+	body := -1
+	for k := range ps {
+		if bodies[k].Name == name {
+			body = k
+		}
+	}
+	if body == -1 {
+		return Maneuver{}, fmt.Errorf("transfer for unknown body %q", name)
+	}
+	v := ps[p.from].Velocity.Sub(ps[p.central].Velocity).Add(p.burns[i][j])
+	dv := v.Sub(ps[body].Velocity.Sub(ps[p.central].Velocity))
+	return Maneuver{
+		Body:    bodies[body].Name,
+		Primary: bodies[p.central].Name,
+		Time:    p.Departures[i],
+		DeltaV:  toLocal(dv, &ps[body], &ps[p.central]),
+	}, nil
+}
+
+// Writes the grid as csv, with a row per departure and a column per arrival, in seconds and m/s.
+func (p *Porkchop) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	row := []string{"departure\\arrival"}
+	for _, a := range p.Arrivals {
+		row = append(row, formatFloat(units.ToSI(a, dimTime)))
+	}
+	cw.Write(row)
+	for i, d := range p.Departures {
+		row = []string{formatFloat(units.ToSI(d, dimTime))}
+		for _, dv := range p.DeltaV[i] {
+			if math.IsNaN(dv) {
+				row = append(row, "")
+			} else {
+				row = append(row, formatFloat(units.ToSI(dv, dimSpeed)))
+			}
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (p *Porkchop) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.WriteCSV(f)
+}
+
+// TransferPlanner scans the configured transfer window in the background, as the scan integrates
+// the system far ahead, and schedules the cheapest transfer once it is done.
+type TransferPlanner struct {
+	config  TransferConfig
+	step    float64
+	results chan transferScan
+	running bool
+}
+
+type transferScan struct {
+	porkchop *Porkchop
+	err      error
+	start    ParticleSystem // the state scanned from
+}
+
+func NewTransferPlanner(c TransferConfig, step float64) *TransferPlanner {
+	return &TransferPlanner{config: c, step: step, results: make(chan transferScan, 1)}
+}
+
+// Starts a scan from a copy of ps at the time t, unless one is still running.
+func (p *TransferPlanner) Start(s Schedule, ps ParticleSystem, bodies []Body, prim []int, t float64) {
+	if p.running {
+		log.Println("transfer planning still running")
+		return
+	}
+	p.running = true
+	ps = append(ParticleSystem(nil), ps...)
+	go func() {
+		pc, err := NewPorkchop(p.config, s, ps, bodies, prim, t, p.step)
+		p.results <- transferScan{pc, err, ps}
+	}()
+}
+
+// Collects a finished scan, writes the porkchop grid and schedules the cheapest transfer for the
+// configured body into s, which invalidates the prediction. ps is the state at the time t.
+func (p *TransferPlanner) Update(s *Schedule, pred *Predictor, ps ParticleSystem, bodies []Body, prim []int, t float64) {
+	var r transferScan
+	select {
+	case r = <-p.results:
+		p.running = false
+	default:
+		return
+	}
+	if r.err != nil {
+		log.Println("transfer planning failed:", r.err)
+		return
+	}
+	pc := r.porkchop
+	if err := pc.WriteFile(porkchopPath); err != nil {
+		log.Println("porkchop export failed:", err)
+	}
+	mu := s.dynamics.Constants.G * r.start[pc.central].Mass
+	r1 := r.start[pc.from].Position.Sub(r.start[pc.central].Position).Len()
+	r2 := r.start[pc.to].Position.Sub(r.start[pc.central].Position).Len()
+	dv1, dv2, tof := Hohmann(r1, r2, mu)
+	fmt.Printf("\nhohmann estimate: %.0f m/s in %.1f days\n", units.ToSI(dv1+dv2, dimSpeed), days(tof))
+
+	i, j := pc.Best()
+	if i == -1 {
+		log.Println("no transfer found in the window")
+		return
+	}
+	fmt.Printf("cheapest transfer: %.0f m/s, departure at day %.1f, arrival at day %.1f\n",
+		units.ToSI(pc.DeltaV[i][j], dimSpeed), days(pc.Departures[i]), days(pc.Arrivals[j]))
+	if p.config.Body == "" {
+		return
+	}
+	m, err := pc.Maneuver(i, j, p.config.Body, bodies)
+	if err == nil && m.Time < t {
+		err = fmt.Errorf("departure at day %.1f passed during the scan", days(m.Time))
+	}
+	if err == nil {
+		err = s.Add(m, ps, t, bodies, prim)
+	}
+	if err != nil {
+		log.Println("transfer not scheduled:", err)
+		return
+	}
+	pred.Invalidate()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// The scan runs in the background and its transfer is scheduled when it is collected.
+func TestTransferPlanner(t *testing.T) {
+	// the porkchop grid is written to the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	circular := func(r, angle float64) Particle {
+		dir := mgl64.Vec3{math.Cos(angle), 0, math.Sin(angle)}
+		speed := math.Sqrt(G * solarMass / r)
+		return Particle{Position: dir.Mul(r), Velocity: mgl64.Vec3{-dir[2], 0, dir[0]}.Mul(speed), Orientation: mgl64.QuatIdent()}
+	}
+	earth, mars := circular(astronomicalUnit, 0), circular(1.524*astronomicalUnit, 1)
+	earth.Mass, mars.Mass = 5.97e24, 6.42e23
+	satellite := earth
+	satellite.Position = satellite.Position.Add(mgl64.Vec3{0, 1e9, 0})
+	satellite.Mass, satellite.Tracer = 1e3, true
+	ps := ParticleSystem{{Mass: solarMass, Orientation: mgl64.QuatIdent()}, earth, mars, satellite}
+	bodies := []Body{{Name: "sun"}, {Name: "earth"}, {Name: "mars"}, {Name: "satellite"}}
+	prim := []int{-1, 0, 0, 0}
+
+	c := TransferConfig{"earth", "mars", "satellite", []float64{0, 400 * secondsPerDay}, []float64{100 * secondsPerDay, 700 * secondsPerDay}, 10}
+	s, err := NewSchedule(nil, ps, 0, bodies, prim, NewDynamics(Config{}, ps, bodies, Layout{}, siConstants))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pred := NewPredictor(PredictionConfig{10, defaultPredictionStep}, s, s.dynamics, &Frame{FrameInertial, -1, -1})
+	p := NewTransferPlanner(c, 5*secondsPerDay)
+	p.Start(*s, ps, bodies, prim, 0)
+	for deadline := time.Now().Add(time.Minute); p.running && time.Now().Before(deadline); {
+		p.Update(s, pred, ps, bodies, prim, 0)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if p.running {
+		t.Fatal("scan did not finish")
+	}
+	if len(s.maneuvers) != 1 || s.maneuvers[0].body != 3 {
+		t.Fatalf("scheduled %v", s.maneuvers)
+	}
+	if pred.generation != 1 {
+		t.Errorf("prediction not invalidated")
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SI value of the units that can follow a number in the config, e.g. "1.0 AU" or "29.8 km/s".
+// Units combine with *, / and ^, everything after the first / is in the denominator.
+var unitValues = map[string]float64{
+	"1": 1,
+
+	"m":         1,
+	"cm":        1e-2,
+	"km":        1e3,
+	"AU":        astronomicalUnit,
+	"au":        astronomicalUnit,
+	"ly":        9.4607304725808e15,
+	"pc":        3.0856775814913673e16,
+	"R_earth":   6.3781e6, // nominal equatorial radii
+	"R_jupiter": 7.1492e7,
+	"R_sun":     6.957e8,
+
+	"s":   1,
+	"min": 60,
+	"h":   3600,
+	"d":   secondsPerDay,
+	"yr":  365.25 * secondsPerDay, // julian year
+
+	"kg":        1,
+	"g":         1e-3,
+	"t":         1e3,
+	"M_moon":    7.342e22,
+	"M_earth":   5.9722e24,
+	"M_jupiter": 1.89813e27,
+	"M_sun":     solarMass,
+
+	"rad": 1,
+	"deg": math.Pi / 180,
+
+	"N":     1,
+	"J":     1,
+	"W":     1,
+	"L_sun": 3.828e26,
+}
+
+const (
+	astronomicalUnit = 1.495978707e11
+	solarMass        = 1.98847e30
+)
+
+// SI value of a unit expression like "km/s", "m/s^2" or "kg*m^2".
+func parseUnit(s string) (float64, error) {
+	parts := strings.Split(s, "/")
+	v := 1.0
+	for i, p := range parts {
+		for _, f := range strings.Split(p, "*") {
+			name, exp := f, 1.0
+			if k := strings.Index(f, "^"); k != -1 {
+				e, err := strconv.ParseFloat(f[k+1:], 64)
+				if err != nil {
+					return 0, fmt.Errorf("bad exponent in unit %q", s)
+				}
+				name, exp = f[:k], e
+			}
+			u, ok := unitValues[name]
+			if !ok {
+				return 0, fmt.Errorf("unknown unit %q in %q", name, s)
+			}
+			if i > 0 {
+				exp = -exp
+			}
+			v *= math.Pow(u, exp)
+		}
+	}
+	return v, nil
+}
+
+// Value in SI units of a quantity written as a number followed by a unit. The second result
+// is false if s is not a number and a known unit, which leaves it to be an ordinary string like "1 Ceres".
+func parseQuantity(s string) (float64, bool) {
+	f := strings.Fields(s)
+	if len(f) < 2 {
+		return 0, false
+	}
+	x, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return 0, false
+	}
+	u, err := parseUnit(strings.Join(f[1:], ""))
+	if err != nil {
+		return 0, false
+	}
+	return x * u, true
+}
+
+// Replaces the quantities with units among the decoded toml values by their values in SI units.
+func resolveQuantities(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if x, ok := parseQuantity(v); ok {
+			return x
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = resolveQuantities(e)
+		}
+	case []map[string]interface{}:
+		for _, e := range v {
+			resolveQuantities(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = resolveQuantities(e)
+		}
+	}
+	return v
+}
+
+type UnitSystem int
+
+const (
+	UnitsSI           UnitSystem = iota // meter, second and kilogram
+	UnitsAstronomical                   // astronomical unit, day and solar mass
+	UnitsNBody                          // G is 1, the mass unit is the mass of all massive bodies
+	unitSystems
+)
+
+var unitSystemNames = [unitSystems]string{"si", "astronomical", "nbody"}
+
+func (s UnitSystem) String() string {
+	if s < 0 || s >= unitSystems {
+		return fmt.Sprintf("UnitSystem(%d)", int(s))
+	}
+	return unitSystemNames[s]
+}
+
+func (s *UnitSystem) UnmarshalText(text []byte) error {
+	for i, n := range unitSystemNames {
+		if n == string(text) {
+			*s = UnitSystem(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown unit system %q", text)
+}
+
+// UnitsConfig selects the units the system is integrated in. The config itself is always read in SI
+// units, unless a value carries its unit.
+type UnitsConfig struct {
+	System UnitSystem
+	Length float64 // of the n-body units, an astronomical unit if not set
+}
+
+// Units are the SI values of the units of length, time and mass of the simulation.
+type Units struct {
+	Length float64
+	Time   float64
+	Mass   float64
+}
+
+// Dimension holds the exponents of length, time and mass of a quantity.
+type Dimension [3]float64
+
+var (
+	dimLength   = Dimension{1, 0, 0}
+	dimTime     = Dimension{0, 1, 0}
+	dimMass     = Dimension{0, 0, 1}
+	dimRate     = Dimension{0, -1, 0}
+	dimSpeed    = Dimension{1, -1, 0}
+	dimAccel    = Dimension{1, -2, 0}
+	dimArea     = Dimension{2, 0, 0}
+	dimDensity  = Dimension{-3, 0, 1}
+	dimForce    = Dimension{1, -2, 1}
+	dimPower    = Dimension{2, -3, 1}
+	dimInertia  = Dimension{2, 0, 1}
+	dimTorque   = Dimension{2, -2, 1}
+	dimGravity  = Dimension{3, -2, -1}
+	dimEpsilon0 = Dimension{-3, 2, -1} // per coulomb squared, charges stay in coulomb
+)
+
+var siUnits = Units{1, 1, 1}
+
+// the units of the simulation, set by useUnits
+var units = siUnits
+
+func (u Units) scale(d Dimension) float64 {
+	return math.Pow(u.Length, d[0]) * math.Pow(u.Time, d[1]) * math.Pow(u.Mass, d[2])
+}
+
+// Converts the SI value x of a quantity of dimension d into the units u.
+func (u Units) FromSI(x float64, d Dimension) float64 {
+	return x / u.scale(d)
+}
+
+// Converts x of dimension d from the units u into SI units.
+func (u Units) ToSI(x float64, d Dimension) float64 {
+	return x * u.scale(d)
+}
+
+func (u Units) fromSI(xs []float64, d Dimension) {
+	for i := range xs {
+		xs[i] = u.FromSI(xs[i], d)
+	}
+}
+
+func newUnits(c Config) Units {
+	switch c.Units.System {
+	case UnitsAstronomical:
+		return Units{astronomicalUnit, secondsPerDay, solarMass}
+	case UnitsNBody:
+		l := c.Units.Length
+		if l == 0 {
+			l = astronomicalUnit
+		}
+		m := 0.0
+		for _, b := range c.Bodies {
+			if !b.Tracer && b.Lagrange == "" {
+				m += b.Mass
+			}
+		}
+		return Units{l, math.Sqrt(l * l * l / (G * m)), m}
+	}
+	return siUnits
+}
+
+// The physical constants in the units u.
+func (u Units) Constants() Constants {
+	return Constants{
+		G:            u.FromSI(G, dimGravity),
+		Eps0:         u.FromSI(Eps0, dimEpsilon0),
+		G0:           u.FromSI(G0, dimAccel),
+		SpeedOfLight: u.FromSI(SpeedOfLight, dimSpeed),
+	}
+}
+
+// Makes u the units the simulation is displayed and rendered in.
+func useUnits(u Units) {
+	units = u
+	glCorrectionScale = glUnitsPerMeter * u.Length
+}
+
+// Converts the quantities of the config, which are read in SI units, into the units u.
+// Angles, ratios and counts are left as they are.
+func (c *Config) convert(u Units) {
+	for i := range c.Bodies {
+		b := &c.Bodies[i]
+		b.Distance = u.FromSI(b.Distance, dimLength)
+		b.Speed = u.FromSI(b.Speed, dimSpeed)
+		b.Mass = u.FromSI(b.Mass, dimMass)
+		b.DryMass = u.FromSI(b.DryMass, dimMass)
+		b.Diameter = u.FromSI(b.Diameter, dimLength)
+		b.RotationPeriod = u.FromSI(b.RotationPeriod, dimTime)
+		u.fromSI(b.Inertia, dimInertia)
+		u.fromSI(b.Torque, dimTorque)
+		u.fromSI(b.AngularVelocity, dimRate)
+		b.ReferenceRadius = u.FromSI(b.ReferenceRadius, dimLength)
+		if b.Atmosphere != nil {
+			b.Atmosphere.Density = u.FromSI(b.Atmosphere.Density, dimDensity)
+			b.Atmosphere.ScaleHeight = u.FromSI(b.Atmosphere.ScaleHeight, dimLength)
+		}
+		b.Area = u.FromSI(b.Area, dimArea)
+		b.Luminosity = u.FromSI(b.Luminosity, dimPower)
+		if b.Tides != nil {
+			b.Tides.TimeLag = u.FromSI(b.Tides.TimeLag, dimTime)
+		}
+	}
+	for i := range c.Belts {
+		b := &c.Belts[i]
+		b.Inner = u.FromSI(b.Inner, dimLength)
+		b.Outer = u.FromSI(b.Outer, dimLength)
+		b.MassMin = u.FromSI(b.MassMin, dimMass)
+		b.MassMax = u.FromSI(b.MassMax, dimMass)
+		b.Diameter = u.FromSI(b.Diameter, dimLength)
+	}
+	for i := range c.Maneuvers {
+		m := &c.Maneuvers[i]
+		m.Time = u.FromSI(m.Time, dimTime)
+		u.fromSI(m.DeltaV, dimSpeed)
+		m.Thrust = u.FromSI(m.Thrust, dimForce)
+		m.Duration = u.FromSI(m.Duration, dimTime)
+		m.Isp = u.FromSI(m.Isp, dimTime)
+	}
+	for i := range c.Events {
+		c.Events[i].Distance = u.FromSI(c.Events[i].Distance, dimLength)
+	}
+	u.fromSI(c.Transfer.Departure, dimTime)
+	u.fromSI(c.Transfer.Arrival, dimTime)
+	c.Trails.Interval = u.FromSI(c.Trails.Interval, dimTime)
+	c.Prediction.Step = u.FromSI(c.Prediction.Step, dimTime)
+}
+
+// Simulated time in days.
+func days(t float64) float64 {
+	return units.ToSI(t, dimTime) / secondsPerDay
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	cases := []struct {
+		s  string
+		x  float64
+		ok bool
+	}{
+		{"1 AU", astronomicalUnit, true},
+		{"1.5e3 m", 1500, true},
+		{"29.8 km/s", 29800, true},
+		{"29.8 km / s", 29800, true},
+		{"-2 km/s", -2000, true},
+		{"9.80665 m/s^2", 9.80665, true},
+		{"2 kg*m^2", 2, true},
+		{"1 km/h/s", 1000.0 / 3600, true}, // everything after the first / is in the denominator
+		{"3 M_earth", 3 * 5.9722e24, true},
+		{"180 deg", math.Pi, true},
+		{"1 d", secondsPerDay, true},
+		{"12", 0, false},
+		{"", 0, false},
+		{"earth", 0, false},
+		{"1 Ceres", 0, false},
+		{"one AU", 0, false},
+		{"1 m/s^x", 0, false},
+	}
+	for _, c := range cases {
+		x, ok := parseQuantity(c.s)
+		if ok != c.ok || math.Abs(x-c.x) > 1e-12*math.Abs(c.x) {
+			t.Errorf("parseQuantity(%q) = %v, %v, want %v, %v", c.s, x, ok, c.x, c.ok)
+		}
+	}
+}
+
+// Quantities are replaced at any depth, everything else is left alone.
+func TestResolveQuantities(t *testing.T) {
+	raw := map[string]interface{}{
+		"units": map[string]interface{}{"system": "astronomical"},
+		"bodies": []map[string]interface{}{
+			{"name": "1 Ceres", "distance": "2.77 AU", "mass": 9.38e20},
+		},
+		"transfer": map[string]interface{}{
+			"departure": []interface{}{"0 d", "2 yr"},
+			"samples":   int64(50),
+		},
+	}
+	resolveQuantities(raw)
+
+	if s := raw["units"].(map[string]interface{})["system"]; s != "astronomical" {
+		t.Errorf("system became %v", s)
+	}
+	b := raw["bodies"].([]map[string]interface{})[0]
+	if b["name"] != "1 Ceres" {
+		t.Errorf("name became %v", b["name"])
+	}
+	if b["distance"] != 2.77*astronomicalUnit {
+		t.Errorf("distance became %v", b["distance"])
+	}
+	if b["mass"] != 9.38e20 {
+		t.Errorf("bare mass became %v", b["mass"])
+	}
+	tr := raw["transfer"].(map[string]interface{})
+	if d := tr["departure"].([]interface{}); d[0] != 0.0 || d[1] != 2*365.25*secondsPerDay {
+		t.Errorf("departure became %v", d)
+	}
+	if tr["samples"] != int64(50) {
+		t.Errorf("samples became %v", tr["samples"])
+	}
+}
+
+// A config mixing quantities and bare SI numbers ends up in the configured units.
+func TestLoadConfigUnits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "system.toml")
+	err := os.WriteFile(path, []byte(`
+[units]
+system = "astronomical"
+[prediction]
+step = "6 h"
+[[bodies]]
+name = "1 Ceres"
+distance = "2.77 AU"
+speed = 17900.0
+mass = "1 M_sun"
+diameter = "939.4 km"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, u := loadConfig(path)
+
+	if u != (Units{astronomicalUnit, secondsPerDay, solarMass}) {
+		t.Errorf("units %v", u)
+	}
+	b := c.Bodies[0]
+	if b.Name != "1 Ceres" {
+		t.Errorf("name %q", b.Name)
+	}
+	for _, q := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"distance", b.Distance, 2.77},
+		{"speed", b.Speed, 17900 * secondsPerDay / astronomicalUnit},
+		{"mass", b.Mass, 1},
+		{"diameter", b.Diameter, 939.4e3 / astronomicalUnit},
+		{"prediction step", c.Prediction.Step, 0.25},
+	} {
+		if math.Abs(q.got-q.want) > 1e-12*q.want {
+			t.Errorf("%s is %v, want %v", q.name, q.got, q.want)
+		}
+	}
+}
+
+// The gravitational constant is 1 in n-body units, whatever the length unit.
+func TestNBodyConstants(t *testing.T) {
+	c := Config{Bodies: []Celestialbody{{Mass: solarMass}, {Mass: 5.9722e24}, {Mass: 1e3, Tracer: true}}}
+	c.Units = UnitsConfig{UnitsNBody, 1e9}
+	k := newUnits(c).Constants()
+	if math.Abs(k.G-1) > 1e-12 {
+		t.Errorf("G is %v", k.G)
+	}
+	if s := siUnits.Constants(); s != siConstants {
+		t.Errorf("si constants %v", s)
+	}
+}